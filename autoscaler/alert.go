@@ -0,0 +1,73 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// AlertAction maps an Alertmanager alert to a scaling action.
+type AlertAction struct {
+	Name   string `toml:"name" yaml:"name"`
+	Action int    `toml:"action" yaml:"action"`
+	// If set, scale by this much when the alert resolves, e.g. the opposite of Action.
+	ResolvedAction int `toml:"resolved_action" yaml:"resolved_action"`
+}
+
+// HandleAlert scales according to the AlertAction for the named alert, if there is
+// one. Alertmanager re-sends alerts while they fire, so key identifies the alert
+// (e.g. its fingerprint) and only the first notification that it is firing, and the
+// first that it has resolved after that, scale.
+func (a *Autoscaler) HandleAlert(ctx context.Context, name, key string, firing bool) error {
+	for _, alert := range a.CurrentRules().Alerts {
+		if alert.Name != name {
+			continue
+		}
+		if !a.alertTransitioned(key, firing) {
+			a.Logger.Debug("ignoring repeated alert notification", slog.String("alert", name), slog.Bool("firing", firing))
+			return nil
+		}
+		action, state := alert.Action, "firing"
+		if !firing {
+			action, state = alert.ResolvedAction, "resolved"
+		}
+		if action == 0 {
+			return nil
+		}
+		a.Logger.Info("alert received", slog.String("alert", name), slog.String("state", state), slog.Int("action", action))
+		ok, reason, err := a.CanScale(ctx, action)
+		if err != nil {
+			return fmt.Errorf("failed to check if can scale: %w", err)
+		}
+		if !ok {
+			a.Logger.Info("not scaling for alert", slog.String("alert", name), slog.String("reason", string(reason)))
+			return nil
+		}
+		return a.doScale(ctx, scaleRequest{direction: action, trigger: fmt.Sprintf("alert: %s (%s)", name, state)})
+	}
+	a.Logger.Debug("ignoring unmapped alert", slog.String("alert", name))
+	return nil
+}
+
+// alertTransitioned records whether the alert identified by key is firing, and
+// reports whether that changed. An alert resolving that wasn't seen firing, e.g.
+// because mcas restarted in between, doesn't count as a change.
+func (a *Autoscaler) alertTransitioned(key string, firing bool) bool {
+	a.alertsMux.Lock()
+	defer a.alertsMux.Unlock()
+	if firing {
+		if a.alertsFiring[key] {
+			return false
+		}
+		if a.alertsFiring == nil {
+			a.alertsFiring = make(map[string]bool)
+		}
+		a.alertsFiring[key] = true
+		return true
+	}
+	if !a.alertsFiring[key] {
+		return false
+	}
+	delete(a.alertsFiring, key)
+	return true
+}
@@ -0,0 +1,30 @@
+package autoscaler
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestHandleAlertOnlyActsOnTransitions(t *testing.T) {
+	a, provider, _, _ := newTestAutoscaler(t, AutoScalerConfig{Alerts: []AlertAction{{Name: "MinecraftLagging", Action: 1, ResolvedAction: -1}}})
+	notifications := []struct {
+		fingerprint string
+		firing      bool
+	}{
+		{"a1", true},
+		{"a1", true},
+		{"a1", true},
+		{"a1", false},
+		{"a1", false},
+		{"b2", false},
+	}
+	for _, n := range notifications {
+		if err := a.HandleAlert(context.Background(), "MinecraftLagging", n.fingerprint, n.firing); err != nil {
+			t.Fatalf("HandleAlert(%s, %v) error = %v", n.fingerprint, n.firing, err)
+		}
+	}
+	if got, want := provider.Resizes(), []string{"cax31", "cax21"}; !slices.Equal(got, want) {
+		t.Errorf("resizes = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,229 @@
+package autoscaler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CalendarEvent is an event from an iCalendar feed whose summary names a size to
+// scale to, e.g. "Build night [mcas:cax31]".
+type CalendarEvent struct {
+	Summary    string
+	Start, End time.Time
+	Size       string
+}
+
+var calendarDirectiveRe = regexp.MustCompile(`(?i)\[mcas:([^\]\s]+)\]`)
+
+const defaultCalendarRefresh = 15 * time.Minute
+
+var calendarClient = &http.Client{Timeout: 30 * time.Second}
+
+// FetchCalendar downloads an iCalendar (.ics) feed and returns its events that have a size directive.
+func FetchCalendar(ctx context.Context, url string) ([]CalendarEvent, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create calendar request: %w", err)
+	}
+	resp, err := calendarClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch calendar: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("failed to fetch calendar: %s", resp.Status)
+	}
+	return parseCalendar(resp.Body)
+}
+
+// parseCalendar reads the VEVENTs of an iCalendar feed. Recurring events only
+// count for their first occurrence. Events with invalid fields are logged and
+// skipped, so one bad event doesn't hide the rest of the feed.
+func parseCalendar(r io.Reader) ([]CalendarEvent, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		// Long lines are folded onto following lines that start with whitespace.
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read calendar: %w", err)
+	}
+
+	var events []CalendarEvent
+	var event *CalendarEvent
+	var duration time.Duration
+	var invalid bool
+	for _, line := range lines {
+		nameAndParams, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		name, params, _ := strings.Cut(nameAndParams, ";")
+		switch strings.ToUpper(name) {
+		case "BEGIN":
+			if strings.EqualFold(value, "VEVENT") {
+				event, duration, invalid = &CalendarEvent{}, 0, false
+			}
+			continue
+		case "END":
+			if !strings.EqualFold(value, "VEVENT") || event == nil {
+				continue
+			}
+			if invalid {
+				event = nil
+				continue
+			}
+			if event.End.IsZero() {
+				event.End = event.Start.Add(duration)
+			}
+			if m := calendarDirectiveRe.FindStringSubmatch(event.Summary); m != nil && !event.Start.IsZero() {
+				event.Size = m[1]
+				events = append(events, *event)
+			}
+			event = nil
+			continue
+		}
+		if event == nil {
+			continue
+		}
+		var err error
+		switch strings.ToUpper(name) {
+		case "SUMMARY":
+			event.Summary = unescapeCalendarText(value)
+		case "DTSTART":
+			event.Start, err = parseCalendarTime(params, value)
+			if err == nil && duration == 0 && isCalendarDate(value) {
+				// All-day events without an end last the day.
+				duration = 24 * time.Hour
+			}
+		case "DTEND":
+			event.End, err = parseCalendarTime(params, value)
+		case "DURATION":
+			duration, err = parseCalendarDuration(value)
+		}
+		if err != nil {
+			slog.Warn("skipping calendar event with an invalid field", slog.String("field", name), slog.String("event", event.Summary), slog.String("error", err.Error()))
+			invalid = true
+		}
+	}
+	slices.SortFunc(events, func(a, b CalendarEvent) int { return a.Start.Compare(b.Start) })
+	return events, nil
+}
+
+func unescapeCalendarText(s string) string {
+	return strings.NewReplacer(`\n`, " ", `\N`, " ", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(s)
+}
+
+// isCalendarDate reports whether a DTSTART or DTEND is a whole day rather than a time.
+func isCalendarDate(value string) bool {
+	return !strings.Contains(value, "T")
+}
+
+// parseCalendarTime parses a DATE or DATE-TIME value, in UTC, the given TZID, or local time.
+func parseCalendarTime(params, value string) (time.Time, error) {
+	loc := time.Local
+	for _, param := range strings.Split(params, ";") {
+		if k, v, ok := strings.Cut(param, "="); ok && strings.EqualFold(k, "TZID") {
+			if l, err := time.LoadLocation(strings.Trim(v, `"`)); err == nil {
+				loc = l
+			}
+		}
+	}
+	switch {
+	case isCalendarDate(value):
+		return time.ParseInLocation("20060102", value, loc)
+	case strings.HasSuffix(value, "Z"):
+		return time.Parse("20060102T150405Z", value)
+	default:
+		return time.ParseInLocation("20060102T150405", value, loc)
+	}
+}
+
+var calendarDurationRe = regexp.MustCompile(`^P(?:(\d+)W)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+)S)?)?$`)
+
+// parseCalendarDuration parses a (positive) DURATION value, e.g. PT2H30M.
+func parseCalendarDuration(value string) (time.Duration, error) {
+	m := calendarDurationRe.FindStringSubmatch(strings.TrimPrefix(value, "+"))
+	if m == nil {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour, time.Minute, time.Second} {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		d += time.Duration(n) * unit
+	}
+	return d, nil
+}
+
+// calendarEvents returns the events from CalendarURL, re-fetching them if they are
+// older than CalendarRefresh. If fetching fails, the previous events are kept.
+func (a *Autoscaler) calendarEvents(ctx context.Context) []CalendarEvent {
+	refresh := a.CalendarRefresh
+	if refresh <= 0 {
+		refresh = defaultCalendarRefresh
+	}
+	a.calendarMux.Lock()
+	defer a.calendarMux.Unlock()
+	if !a.calendarFetchedAt.IsZero() && a.Clock.Now().Sub(a.calendarFetchedAt) < refresh {
+		return a.calendarCache
+	}
+	events, err := FetchCalendar(ctx, a.CalendarURL)
+	if err != nil {
+		a.Logger.Warn("failed to refresh calendar, using previous events", slog.String("error", err.Error()), slog.Int("events", len(a.calendarCache)))
+		return a.calendarCache
+	}
+	a.Logger.Debug("refreshed calendar", slog.Int("events", len(events)))
+	a.calendarCache, a.calendarFetchedAt = events, a.Clock.Now()
+	return events
+}
+
+// enforceCalendar scales to the size of the first calendar event that is on (or
+// starts within CalendarLead), if any, and reports whether one was.
+func (a *Autoscaler) enforceCalendar(ctx context.Context) (bool, error) {
+	if a.CalendarURL == "" {
+		return false, nil
+	}
+	now := a.Clock.Now()
+	for _, e := range a.calendarEvents(ctx) {
+		if now.Before(e.Start.Add(-a.CalendarLead)) || !now.Before(e.End) {
+			continue
+		}
+		if !slices.Contains(a.AllowedSizes, e.Size) {
+			a.Logger.Warn("calendar event's size is not an allowed size, ignoring it", slog.String("event", e.Summary), slog.String("size", e.Size))
+			continue
+		}
+		current, sizes, err := a.getCurrentSize(ctx)
+		if err != nil {
+			return true, fmt.Errorf("failed to get current size: %w", err)
+		}
+		if sizes[current] == e.Size {
+			a.Logger.Debug("calendar event on, already at its size", slog.String("event", e.Summary), slog.String("size", e.Size))
+			return true, nil
+		}
+		a.Logger.Info("calendar event on, scaling to its size", slog.String("event", e.Summary), slog.Time("start", e.Start), slog.Time("end", e.End), slog.String("current", sizes[current]), slog.String("target", e.Size))
+		return true, a.doScale(ctx, scaleRequest{target: e.Size, trigger: "calendar: " + e.Summary})
+	}
+	return false, nil
+}
@@ -0,0 +1,172 @@
+package autoscaler
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCalendar(t *testing.T) {
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skipf("no time zone database: %v", err)
+	}
+	tests := []struct {
+		name string
+		ics  string
+		want []CalendarEvent
+	}{
+		{
+			name: "folded summary",
+			ics: `BEGIN:VEVENT
+SUMMARY:Build night with a very long description of what we're bu
+ ilding [mcas:
+ cax31]
+DTSTART:20250601T180000Z
+DTEND:20250601T220000Z
+END:VEVENT`,
+			want: []CalendarEvent{{
+				Summary: "Build night with a very long description of what we're building [mcas:cax31]",
+				Start:   time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC),
+				End:     time.Date(2025, 6, 1, 22, 0, 0, 0, time.UTC),
+				Size:    "cax31",
+			}},
+		},
+		{
+			name: "TZID",
+			ics: `BEGIN:VEVENT
+SUMMARY:Event [mcas:cax41]
+DTSTART;TZID=Europe/London:20250601T180000
+DTEND;TZID="Europe/London":20250601T200000
+END:VEVENT`,
+			want: []CalendarEvent{{
+				Summary: "Event [mcas:cax41]",
+				Start:   time.Date(2025, 6, 1, 18, 0, 0, 0, london),
+				End:     time.Date(2025, 6, 1, 20, 0, 0, 0, london),
+				Size:    "cax41",
+			}},
+		},
+		{
+			name: "all-day DATE without an end",
+			ics: `BEGIN:VEVENT
+SUMMARY:Launch day [mcas:cax41]
+DTSTART;VALUE=DATE:20250601
+END:VEVENT`,
+			want: []CalendarEvent{{
+				Summary: "Launch day [mcas:cax41]",
+				Start:   time.Date(2025, 6, 1, 0, 0, 0, 0, time.Local),
+				End:     time.Date(2025, 6, 2, 0, 0, 0, 0, time.Local),
+				Size:    "cax41",
+			}},
+		},
+		{
+			name: "DATE-TIME without an end",
+			ics: `BEGIN:VEVENT
+SUMMARY:Instant [mcas:cax21]
+DTSTART:20250601T180000Z
+END:VEVENT`,
+			want: []CalendarEvent{{
+				Summary: "Instant [mcas:cax21]",
+				Start:   time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC),
+				End:     time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC),
+				Size:    "cax21",
+			}},
+		},
+		{
+			name: "DURATION",
+			ics: `BEGIN:VEVENT
+SUMMARY:Event [mcas:cax31]
+DTSTART:20250601T180000Z
+DURATION:PT2H30M
+END:VEVENT`,
+			want: []CalendarEvent{{
+				Summary: "Event [mcas:cax31]",
+				Start:   time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC),
+				End:     time.Date(2025, 6, 1, 20, 30, 0, 0, time.UTC),
+				Size:    "cax31",
+			}},
+		},
+		{
+			name: "invalid event skipped, others sorted",
+			ics: `BEGIN:VCALENDAR
+BEGIN:VEVENT
+SUMMARY:Later [mcas:cax41]
+DTSTART:20250602T180000Z
+DTEND:20250602T200000Z
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Broken [mcas:cax41]
+DTSTART:tomorrow
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:No directive
+DTSTART:20250601T100000Z
+END:VEVENT
+BEGIN:VEVENT
+SUMMARY:Earlier [mcas:cax31]
+DTSTART:20250601T180000Z
+DURATION:P1D
+END:VEVENT
+END:VCALENDAR`,
+			want: []CalendarEvent{
+				{
+					Summary: "Earlier [mcas:cax31]",
+					Start:   time.Date(2025, 6, 1, 18, 0, 0, 0, time.UTC),
+					End:     time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC),
+					Size:    "cax31",
+				},
+				{
+					Summary: "Later [mcas:cax41]",
+					Start:   time.Date(2025, 6, 2, 18, 0, 0, 0, time.UTC),
+					End:     time.Date(2025, 6, 2, 20, 0, 0, 0, time.UTC),
+					Size:    "cax41",
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ics := strings.ReplaceAll(tt.ics, "\n", "\r\n")
+			got, err := parseCalendar(strings.NewReader(ics))
+			if err != nil {
+				t.Fatalf("parseCalendar() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("parseCalendar() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				g, w := got[i], tt.want[i]
+				if g.Summary != w.Summary || g.Size != w.Size || !g.Start.Equal(w.Start) || !g.End.Equal(w.End) {
+					t.Errorf("event %d = %+v, want %+v", i, g, w)
+				}
+			}
+		})
+	}
+}
+
+func TestParseCalendarDuration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{value: "PT15M", want: 15 * time.Minute},
+		{value: "+PT1H30M", want: 90 * time.Minute},
+		{value: "P1DT2H", want: 26 * time.Hour},
+		{value: "P2W", want: 14 * 24 * time.Hour},
+		{value: "PT45S", want: 45 * time.Second},
+		{value: "1h", wantErr: true},
+		{value: "PT1.5H", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseCalendarDuration(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseCalendarDuration(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseCalendarDuration(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
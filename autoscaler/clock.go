@@ -0,0 +1,67 @@
+package autoscaler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Clock tells the autoscaler what time it is, so that time-based decisions
+// (cooldowns, holds, time windows and so on) can be made at other times than now.
+type Clock interface {
+	Now() time.Time
+	// Sleep waits for d to pass, or until ctx is done.
+	Sleep(ctx context.Context, d time.Duration) error
+}
+
+// RealClock is the system clock.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+func (RealClock) Sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(d):
+		return nil
+	}
+}
+
+// FakeClock is a Clock that only moves when told to.
+type FakeClock struct {
+	mux sync.Mutex
+	now time.Time
+}
+
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.now
+}
+
+// Sleep advances the clock by d without waiting.
+func (c *FakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.Advance(d)
+	return ctx.Err()
+}
+
+// Set moves the clock to t.
+func (c *FakeClock) Set(t time.Time) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = t
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,144 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	mcnet "github.com/Tnze/go-mc/net"
+)
+
+// MinecraftController is how mcas talks to the Minecraft server itself.
+type MinecraftController interface {
+	// Broadcast shows a message to all players. The message is either plain text
+	// or a tellraw JSON text component.
+	Broadcast(ctx context.Context, message string) error
+	// PlayerCount returns the number of players online.
+	PlayerCount(ctx context.Context) (int, error)
+	// Command runs a console command and returns its output.
+	Command(ctx context.Context, command string) (string, error)
+	// Stop asks the server to save and shut down.
+	Stop(ctx context.Context) error
+	// Close releases any connection held open between calls.
+	Close() error
+}
+
+// RCONController controls the server over RCON. It keeps its connection open
+// between calls until Close is called or a call fails.
+type RCONController struct {
+	address  string
+	password string
+
+	conn *mcnet.RCONConn
+	mux  sync.Mutex
+}
+
+func NewRCONController(address, password string) *RCONController {
+	return &RCONController{address: address, password: password}
+}
+
+// Command runs a command, giving up at the context's deadline (or after
+// rconCommandTimeout) or when it is cancelled. The connection is dropped on any
+// failure, so a server that stopped responding is dialled afresh next time.
+func (c *RCONController) Command(ctx context.Context, command string) (string, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if c.conn == nil {
+		conn, err := dialRCON(ctx, c.address, c.password)
+		if err != nil {
+			return "", fmt.Errorf("failed to dial RCON: %w", err)
+		}
+		c.conn = conn
+	}
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(rconCommandTimeout)
+	}
+	c.conn.SetDeadline(deadline)
+	conn := c.conn
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	err := c.conn.Cmd(command)
+	if err != nil {
+		c.closeUNLOCKED()
+		return "", fmt.Errorf("failed to send %q command: %w", command, contextErr(ctx, err))
+	}
+	resp, err := c.conn.Resp()
+	if err != nil {
+		c.closeUNLOCKED()
+		return "", fmt.Errorf("failed to read response from server: %w", contextErr(ctx, err))
+	}
+	return resp, nil
+}
+
+// contextErr returns the context's error if it is why err happened, e.g. a
+// deadline set from it, or err otherwise.
+func contextErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	// The connection's deadline can pass just before the context notices its own.
+	if deadline, ok := ctx.Deadline(); ok && !time.Now().Before(deadline) {
+		return fmt.Errorf("%w: %w", context.DeadlineExceeded, err)
+	}
+	return err
+}
+
+func (c *RCONController) Broadcast(ctx context.Context, message string) error {
+	var err error
+	if len(message) > 0 && message[0] == '{' {
+		_, err = c.Command(ctx, `tellraw @a `+message)
+	} else {
+		_, err = c.Command(ctx, `say `+message)
+	}
+	return err
+}
+
+var listRe = regexp.MustCompile(`There (?:is|are) (\d+) out of maximum \d+ players? online\..*`)
+
+// formatRe matches legacy formatting codes in either case, including the hex colour
+// form (§x followed by six §-prefixed hex digits) used by Paper/Adventure.
+var formatRe = regexp.MustCompile(`(?i)§x(?:§[0-9a-f]){6}|§[0-9a-z]`)
+
+func (c *RCONController) PlayerCount(ctx context.Context) (int, error) {
+	resp, err := c.Command(ctx, `list`)
+	if err != nil {
+		return 0, err
+	}
+	slog.Debug("list response", slog.String("response", resp))
+	return parsePlayerCount(resp)
+}
+
+// parsePlayerCount returns the number of players online from the output of list.
+func parsePlayerCount(resp string) (int, error) {
+	resp = formatRe.ReplaceAllString(resp, "")
+	match := listRe.FindStringSubmatch(resp)
+	if match == nil {
+		return 0, fmt.Errorf("list response does not match expected format: %q", resp)
+	}
+	return strconv.Atoi(match[1])
+}
+
+func (c *RCONController) Stop(ctx context.Context) error {
+	_, err := c.Command(ctx, `stop`)
+	return err
+}
+
+func (c *RCONController) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	return c.closeUNLOCKED()
+}
+
+func (c *RCONController) closeUNLOCKED() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
@@ -0,0 +1,96 @@
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	mcnet "github.com/Tnze/go-mc/net"
+)
+
+func TestParsePlayerCount(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    string
+		want    int
+		wantErr bool
+	}{
+		{name: "vanilla", resp: "There are 3 out of maximum 20 players online.", want: 3},
+		{name: "one player", resp: "There is 1 out of maximum 20 player online.", want: 1},
+		{name: "with names", resp: "There are 2 out of maximum 20 players online.alice, bob", want: 2},
+		{name: "legacy codes", resp: "§6There are §c5§6 out of maximum §c20§6 players online.", want: 5},
+		{name: "uppercase legacy codes", resp: "§AThere are §L7§R out of maximum 20 players online.", want: 7},
+		{
+			name: "adventure hex colours",
+			resp: "§x§f§f§a§a§0§0There are §x§1§2§3§4§5§612§r out of maximum §x§A§B§C§D§E§F40§r players online.",
+			want: 12,
+		},
+		{name: "hex colour before a digit", resp: "There are §x§0§0§0§0§0§09 out of maximum 20 players online.", want: 9},
+		{name: "unrecognised", resp: "Unknown command", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parsePlayerCount(tt.resp)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parsePlayerCount(%q) error = %v, wantErr %v", tt.resp, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parsePlayerCount(%q) = %d, want %d", tt.resp, got, tt.want)
+			}
+		})
+	}
+}
+
+// A server that stops answering mustn't block the controller for good.
+func TestRCONControllerCommandTimesOut(t *testing.T) {
+	listener, err := mcnet.ListenRCON("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if err := conn.AcceptLogin("secret"); err != nil {
+					return
+				}
+				for {
+					cmd, err := conn.AcceptCmd()
+					if err != nil {
+						return
+					}
+					if cmd == "hang" {
+						// Never answer, but keep the connection open.
+						time.Sleep(time.Minute)
+						return
+					}
+					conn.RespCmd("ok: " + cmd)
+				}
+			}()
+		}
+	}()
+
+	c := NewRCONController(listener.Addr().String(), "secret")
+	defer c.Close()
+	if resp, err := c.Command(context.Background(), "list"); err != nil || resp != "ok: list" {
+		t.Fatalf("Command(list) = %q, %v, want %q", resp, err, "ok: list")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := c.Command(ctx, "hang"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Command(hang) error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Command(hang) took %s to give up", elapsed)
+	}
+	if resp, err := c.Command(context.Background(), "list"); err != nil || resp != "ok: list" {
+		t.Errorf("Command(list) after the timeout = %q, %v, want %q on a new connection", resp, err, "ok: list")
+	}
+}
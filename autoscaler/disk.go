@@ -0,0 +1,33 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// diskUpgradeTarget returns the next allowed size up the ladder with more disk
+// than the server has, or "" if there is none.
+func (a *Autoscaler) diskUpgradeTarget(ctx context.Context) (string, error) {
+	upgrader, ok := a.Scaler.(DiskUpgrader)
+	if !ok {
+		return "", fmt.Errorf("provider can't upgrade the server's disk")
+	}
+	current, sizes, err := a.getCurrentSize(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current size: %w", err)
+	}
+	_, maxIndex := a.sizeBounds(sizes)
+	currentDisk := upgrader.CurrentDiskSize()
+	for i := current + 1; i <= maxIndex; i++ {
+		disk, err := upgrader.DiskSize(sizes[i])
+		if err != nil {
+			return "", fmt.Errorf("failed to get disk size of %s: %w", sizes[i], err)
+		}
+		if disk > currentDisk {
+			return sizes[i], nil
+		}
+	}
+	a.Logger.Info("no allowed size has more disk than the server", slog.String("current", sizes[current]), slog.Int("diskGB", currentDisk))
+	return "", nil
+}
@@ -0,0 +1,37 @@
+package autoscaler
+
+import (
+	"time"
+)
+
+// ScaleEvent is a record of a scaling action that was attempted.
+type ScaleEvent struct {
+	Time    time.Time `json:"time"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Trigger string    `json:"trigger"`
+	Outcome string    `json:"outcome"`
+	Error   string    `json:"error,omitempty"`
+}
+
+const defaultRecentEventsSize = 50
+
+func (a *Autoscaler) recordEvent(e ScaleEvent) {
+	size := a.RecentEventsSize
+	if size <= 0 {
+		size = defaultRecentEventsSize
+	}
+	a.eventsMux.Lock()
+	defer a.eventsMux.Unlock()
+	a.events = append(a.events, e)
+	if len(a.events) > size {
+		a.events = a.events[len(a.events)-size:]
+	}
+}
+
+// RecentEvents returns the most recent scaling actions, oldest first.
+func (a *Autoscaler) RecentEvents() []ScaleEvent {
+	a.eventsMux.Lock()
+	defer a.eventsMux.Unlock()
+	return append([]ScaleEvent(nil), a.events...)
+}
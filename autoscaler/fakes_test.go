@@ -0,0 +1,223 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/markspolakovs/mcas/metrics"
+	"github.com/prometheus/common/model"
+)
+
+// fakeProvider is a Provider whose server resizes instantly.
+type fakeProvider struct {
+	mux        sync.Mutex
+	size       string
+	sizes      []string
+	prices     map[string]float64
+	refreshErr error
+	resizeErr  error
+	stopped    int
+	resizes    []string
+}
+
+func (p *fakeProvider) Refresh(ctx context.Context) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.refreshErr
+}
+
+func (p *fakeProvider) GetCurrentSize(ctx context.Context) (string, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return p.size, nil
+}
+
+func (p *fakeProvider) GetAvailableSizes(ctx context.Context) ([]string, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return slices.Clone(p.sizes), nil
+}
+
+func (p *fakeProvider) Placement() (string, string) {
+	return "arm", "fsn1"
+}
+
+func (p *fakeProvider) GetPrice(ctx context.Context, size string) (float64, error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	price, ok := p.prices[size]
+	if !ok {
+		return 0, fmt.Errorf("no price for %s", size)
+	}
+	return price, nil
+}
+
+func (p *fakeProvider) StopServer(ctx context.Context) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.stopped++
+	return nil
+}
+
+func (p *fakeProvider) ResizeServer(ctx context.Context, size string) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.resizeErr != nil {
+		return p.resizeErr
+	}
+	p.resizes = append(p.resizes, size)
+	p.size = size
+	return nil
+}
+
+func (p *fakeProvider) Resizes() []string {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	return slices.Clone(p.resizes)
+}
+
+// fakeController is a MinecraftController that reports the given player counts
+// in turn, repeating the last one once they run out.
+type fakeController struct {
+	mux        sync.Mutex
+	players    []int
+	broadcasts []string
+	commands   []string
+	stops      int
+	closes     int
+}
+
+func (c *fakeController) Broadcast(ctx context.Context, message string) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.broadcasts = append(c.broadcasts, message)
+	return nil
+}
+
+func (c *fakeController) PlayerCount(ctx context.Context) (int, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	if len(c.players) == 0 {
+		return 0, nil
+	}
+	count := c.players[0]
+	if len(c.players) > 1 {
+		c.players = c.players[1:]
+	}
+	return count, nil
+}
+
+func (c *fakeController) Command(ctx context.Context, command string) (string, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.commands = append(c.commands, command)
+	return "", nil
+}
+
+func (c *fakeController) Stop(ctx context.Context) error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.stops++
+	return nil
+}
+
+func (c *fakeController) Close() error {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.closes++
+	return nil
+}
+
+// fakeMetrics is a metrics.Source that answers queries from a map. Queries that
+// aren't in it return no data.
+type fakeMetrics struct {
+	mux     sync.Mutex
+	values  map[string]model.Value
+	errs    map[string]error
+	queries []string
+}
+
+func (m *fakeMetrics) Query(ctx context.Context, query string) (model.Value, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.queries = append(m.queries, query)
+	if err, ok := m.errs[query]; ok {
+		return nil, err
+	}
+	if v, ok := m.values[query]; ok {
+		return v, nil
+	}
+	return model.Vector{}, nil
+}
+
+func (m *fakeMetrics) QueryScalar(ctx context.Context, query string) (float64, error) {
+	val, err := m.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	switch v := val.(type) {
+	case *model.Scalar:
+		return float64(v.Value), nil
+	case model.Vector:
+		if len(v) == 0 {
+			return 0, fmt.Errorf("%w: %q", metrics.ErrNoData, query)
+		}
+		return float64(v[0].Value), nil
+	default:
+		return 0, fmt.Errorf("expected scalar or vector result, got %T", val)
+	}
+}
+
+func (m *fakeMetrics) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, time.Duration, error) {
+	val, err := m.Query(ctx, query)
+	if err != nil {
+		return nil, 0, err
+	}
+	matrix, _ := val.(model.Matrix)
+	return matrix, step, nil
+}
+
+func (m *fakeMetrics) InvalidateCache() {}
+
+func (m *fakeMetrics) SetNow(now func() time.Time) {}
+
+// vector returns a one-sample vector result with the given value.
+func vector(value float64) model.Vector {
+	return model.Vector{&model.Sample{Value: model.SampleValue(value)}}
+}
+
+// newTestAutoscaler returns an autoscaler using the fakes, a fake clock and a
+// size ladder of cax11 to cax41, with the server at cax21.
+func newTestAutoscaler(t *testing.T, cfg AutoScalerConfig) (*Autoscaler, *fakeProvider, *fakeMetrics, *fakeController) {
+	t.Helper()
+	provider := &fakeProvider{
+		size:   "cax21",
+		sizes:  []string{"cax11", "cax21", "cax31", "cax41"},
+		prices: map[string]float64{"cax11": 0.01, "cax21": 0.02, "cax31": 0.03, "cax41": 0.04},
+	}
+	mcMetrics := &fakeMetrics{values: make(map[string]model.Value), errs: make(map[string]error)}
+	controller := &fakeController{}
+	cfg.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	cfg.Scaler = provider
+	cfg.Metrics = mcMetrics
+	cfg.Controller = controller
+	if cfg.Clock == nil {
+		cfg.Clock = NewFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	}
+	if cfg.AllowedSizes == nil {
+		cfg.AllowedSizes = provider.sizes
+	}
+	cfg.EmptyWaitPollInterval = time.Millisecond
+	for i := range cfg.Rules {
+		if err := cfg.Rules[i].Compile(); err != nil {
+			t.Fatalf("failed to compile rule %d: %v", i, err)
+		}
+	}
+	return NewAutoscaler(cfg), provider, mcMetrics, controller
+}
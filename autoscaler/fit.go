@@ -0,0 +1,51 @@
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+
+	"github.com/markspolakovs/mcas/metrics"
+)
+
+// fitToPlayers returns the smallest size whose SizeCapacity covers the player
+// count from a FitToPlayers rule's query, or "" if the server is already that size.
+// If no size is big enough, the largest is used.
+func (a *Autoscaler) fitToPlayers(ctx context.Context, rule ScaleRule) (string, error) {
+	query, err := a.renderQuery(&rule)
+	if err != nil {
+		return "", err
+	}
+	players, err := a.Metrics.QueryScalar(ctx, query)
+	if errors.Is(err, metrics.ErrNoData) {
+		slog.Debug("rule query returned no data", slog.String("query", query))
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to query for rule %q: %w", rule.Query, err)
+	}
+	current, sizes, err := a.getCurrentSize(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to get current size: %w", err)
+	}
+	minIndex, maxIndex := a.sizeBounds(sizes)
+	target := FitSize(sizes[minIndex:maxIndex+1], a.SizeCapacity, players)
+	slog.Debug("fitting size to players", slog.String("query", query), slog.Float64("players", players), slog.String("current", sizes[current]), slog.String("target", target))
+	if target == sizes[current] {
+		return "", nil
+	}
+	return target, nil
+}
+
+// FitSize returns the first of sizes whose capacity covers players, or the last
+// if none is big enough.
+func FitSize(sizes []string, capacity map[string]int, players float64) string {
+	for _, size := range sizes {
+		if c, ok := capacity[size]; ok && float64(c) >= math.Ceil(players) {
+			return size
+		}
+	}
+	return sizes[len(sizes)-1]
+}
@@ -0,0 +1,99 @@
+package autoscaler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPPluginController controls the server through a plugin or panel that
+// exposes an HTTP API instead of RCON. It expects:
+//
+//	POST {base}/broadcast {"message": "..."}
+//	GET  {base}/players   -> {"online": 3}
+//	POST {base}/command   {"command": "..."} -> {"response": "..."}
+//	POST {base}/stop
+//
+// with the token, if any, sent as a bearer token.
+type HTTPPluginController struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func NewHTTPPluginController(baseURL, token string) *HTTPPluginController {
+	return &HTTPPluginController{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (c *HTTPPluginController) do(ctx context.Context, method, path string, body, result any) error {
+	var reqBody io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reqBody = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s returned %s: %s", path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if result != nil {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			return fmt.Errorf("failed to decode response from %s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+func (c *HTTPPluginController) Broadcast(ctx context.Context, message string) error {
+	return c.do(ctx, http.MethodPost, "/broadcast", map[string]string{"message": message}, nil)
+}
+
+func (c *HTTPPluginController) PlayerCount(ctx context.Context) (int, error) {
+	var result struct {
+		Online int `json:"online"`
+	}
+	err := c.do(ctx, http.MethodGet, "/players", nil, &result)
+	return result.Online, err
+}
+
+func (c *HTTPPluginController) Command(ctx context.Context, command string) (string, error) {
+	var result struct {
+		Response string `json:"response"`
+	}
+	err := c.do(ctx, http.MethodPost, "/command", map[string]string{"command": command}, &result)
+	return result.Response, err
+}
+
+func (c *HTTPPluginController) Stop(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/stop", nil, nil)
+}
+
+func (c *HTTPPluginController) Close() error {
+	return nil
+}
@@ -0,0 +1,100 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/markspolakovs/mcas/mcstatus"
+)
+
+// playerCount returns the number of players online, from PlayerCountQuery or a
+// ping to PingAddress if set, or the controller otherwise.
+func (a *Autoscaler) playerCount(ctx context.Context) (int, error) {
+	if a.PlayerCountQuery != "" {
+		return a.playerCountFromMetrics(ctx)
+	}
+	if a.PingAddress != "" {
+		players, err := mcstatus.Ping(ctx, a.PingAddress)
+		if err != nil {
+			return 0, err
+		}
+		return players.Online, nil
+	}
+	return a.Controller.PlayerCount(ctx)
+}
+
+// updateEmptySince records when the server became empty, or clears it if anyone is online.
+func (a *Autoscaler) updateEmptySince(ctx context.Context) {
+	if a.ScaleDownEmptyFor <= 0 {
+		return
+	}
+	// The controller's connection is shared with scaling, so it is left open.
+	count, err := a.playerCount(ctx)
+	a.emptyMux.Lock()
+	defer a.emptyMux.Unlock()
+	if err != nil {
+		// Without a reading we can't claim the server has been empty throughout.
+		a.Logger.Warn("failed to get player count", slog.String("error", err.Error()))
+		a.emptySince = time.Time{}
+		return
+	}
+	switch {
+	case count > 0:
+		a.emptySince = time.Time{}
+	case a.emptySince.IsZero():
+		a.emptySince = a.Clock.Now()
+	}
+}
+
+// idle reports whether the server has been empty for ScaleDownEmptyFor.
+func (a *Autoscaler) idle() bool {
+	if a.ScaleDownEmptyFor <= 0 {
+		return false
+	}
+	a.emptyMux.Lock()
+	defer a.emptyMux.Unlock()
+	return !a.emptySince.IsZero() && a.Clock.Now().Sub(a.emptySince) >= a.ScaleDownEmptyFor
+}
+
+// scaleToCheapest scales straight to the cheapest size the server may use.
+func (a *Autoscaler) scaleToCheapest(ctx context.Context, trigger string) error {
+	_, sizes, err := a.getCurrentSize(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current size: %w", err)
+	}
+	target, err := a.cheapestSize(ctx, sizes)
+	if err != nil {
+		return err
+	}
+	a.Logger.Info("server is idle, scaling straight to the cheapest size", slog.String("target", target))
+	return a.doScale(ctx, scaleRequest{target: target, trigger: trigger})
+}
+
+// cheapestSize returns the size between MinSize and MaxSize with the lowest price.
+func (a *Autoscaler) cheapestSize(ctx context.Context, sizes []string) (string, error) {
+	minIndex, maxIndex := a.sizeBounds(sizes)
+	cheapest, cheapestPrice := "", 0.0
+	for _, size := range sizes[minIndex : maxIndex+1] {
+		price, err := a.Scaler.GetPrice(ctx, size)
+		if err != nil {
+			return "", fmt.Errorf("failed to get price of %s: %w", size, err)
+		}
+		if cheapest == "" || price < cheapestPrice {
+			cheapest, cheapestPrice = size, price
+		}
+	}
+	return cheapest, nil
+}
+
+// inIdleHold reports whether a scale in the given direction is refused because
+// the server hasn't been empty for ScaleDownEmptyFor yet.
+func (a *Autoscaler) inIdleHold(direction int) bool {
+	if direction >= 0 || a.ScaleDownEmptyFor <= 0 {
+		return false
+	}
+	a.emptyMux.Lock()
+	defer a.emptyMux.Unlock()
+	return a.emptySince.IsZero() || a.Clock.Now().Sub(a.emptySince) < a.ScaleDownEmptyFor
+}
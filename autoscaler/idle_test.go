@@ -0,0 +1,33 @@
+package autoscaler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCheapestSize(t *testing.T) {
+	tests := []struct {
+		name             string
+		prices           map[string]float64
+		minSize, maxSize string
+		want             string
+	}{
+		{name: "bottom of the ladder", prices: map[string]float64{"cax11": 0.01, "cax21": 0.02, "cax31": 0.03, "cax41": 0.04}, want: "cax11"},
+		{name: "cheaper size further up", prices: map[string]float64{"cax11": 0.02, "cax21": 0.015, "cax31": 0.03, "cax41": 0.04}, want: "cax21"},
+		{name: "within min size", prices: map[string]float64{"cax11": 0.01, "cax21": 0.02, "cax31": 0.03, "cax41": 0.025}, minSize: "cax31", want: "cax41"},
+		{name: "within max size", prices: map[string]float64{"cax11": 0.03, "cax21": 0.02, "cax31": 0.01, "cax41": 0.04}, maxSize: "cax21", want: "cax21"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, provider, _, _ := newTestAutoscaler(t, AutoScalerConfig{MinSize: tt.minSize, MaxSize: tt.maxSize})
+			provider.prices = tt.prices
+			got, err := a.cheapestSize(context.Background(), provider.sizes)
+			if err != nil {
+				t.Fatalf("cheapestSize() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("cheapestSize() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Locker is a lock shared with other automation that touches the server.
+type Locker interface {
+	// Lock blocks until the lock is acquired or ctx is done.
+	Lock(ctx context.Context) error
+	Unlock() error
+}
+
+// FileLocker is a Locker using an advisory lock on a file, compatible with flock(1),
+// e.g. flock /run/mcas.lock backup.sh.
+type FileLocker struct {
+	path string
+	f    *os.File
+}
+
+func NewFileLocker(path string) *FileLocker {
+	return &FileLocker{path: path}
+}
+
+const lockPollInterval = time.Second
+
+func (l *FileLocker) Lock(ctx context.Context) error {
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open lock file: %w", err)
+	}
+	for {
+		err := tryLockFile(f)
+		if err == nil {
+			l.f = f
+			return nil
+		}
+		if !errors.Is(err, errLocked) {
+			f.Close()
+			return fmt.Errorf("failed to lock %s: %w", l.path, err)
+		}
+		select {
+		case <-ctx.Done():
+			f.Close()
+			return fmt.Errorf("%s is locked: %w", l.path, ctx.Err())
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+func (l *FileLocker) Unlock() error {
+	if l.f == nil {
+		return nil
+	}
+	err := unlockFile(l.f)
+	l.f.Close()
+	l.f = nil
+	return err
+}
+
+var errLocked = errors.New("locked by another process")
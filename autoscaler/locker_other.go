@@ -0,0 +1,16 @@
+//go:build !unix
+
+package autoscaler
+
+import (
+	"errors"
+	"os"
+)
+
+func tryLockFile(f *os.File) error {
+	return errors.New("file locks are not supported on this platform")
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
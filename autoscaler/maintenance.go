@@ -0,0 +1,19 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+)
+
+// inMaintenance reports whether MaintenanceQuery is met, in the same way as a rule's
+// query, in which case rules and schedules don't scale.
+func (a *Autoscaler) inMaintenance(ctx context.Context) (bool, error) {
+	if a.MaintenanceQuery == "" {
+		return false, nil
+	}
+	val, err := a.Metrics.Query(ctx, a.MaintenanceQuery)
+	if err != nil {
+		return false, fmt.Errorf("failed to query maintenance mode: %w", err)
+	}
+	return resultMet(val)
+}
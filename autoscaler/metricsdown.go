@@ -0,0 +1,83 @@
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/markspolakovs/mcas/metrics"
+	"github.com/prometheus/common/model"
+)
+
+// What to do when rules can't be evaluated because Prometheus is unreachable.
+const (
+	// Stay at the current size.
+	MetricsUnavailableHold = "hold"
+	// Scale to SafeSize once metrics have been unavailable for MetricsUnavailableAfter.
+	MetricsUnavailableScaleToSafe = "scale-to-safe-size"
+)
+
+// ErrMetricsUnavailable is returned (wrapped) when a query fails, as opposed to a
+// rule being invalid. Only these errors count towards OnMetricsUnavailable.
+var ErrMetricsUnavailable = errors.New("metrics unavailable")
+
+// unavailableOnError wraps a metrics.Source's query errors, other than ErrNoData,
+// with ErrMetricsUnavailable.
+type unavailableOnError struct {
+	metrics.Source
+}
+
+func wrapUnavailable(err error) error {
+	if err == nil || errors.Is(err, metrics.ErrNoData) {
+		return err
+	}
+	return fmt.Errorf("%w: %w", ErrMetricsUnavailable, err)
+}
+
+func (s unavailableOnError) Query(ctx context.Context, query string) (model.Value, error) {
+	val, err := s.Source.Query(ctx, query)
+	return val, wrapUnavailable(err)
+}
+
+func (s unavailableOnError) QueryScalar(ctx context.Context, query string) (float64, error) {
+	val, err := s.Source.QueryScalar(ctx, query)
+	return val, wrapUnavailable(err)
+}
+
+func (s unavailableOnError) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, time.Duration, error) {
+	m, step, err := s.Source.QueryRange(ctx, query, start, end, step)
+	return m, step, wrapUnavailable(err)
+}
+
+func (a *Autoscaler) metricsSucceeded() {
+	if a.consecutiveMetricFailures > 0 {
+		a.Logger.Info("metrics available again", slog.Int("failures", a.consecutiveMetricFailures))
+	}
+	a.consecutiveMetricFailures = 0
+	a.metricsFailingSince = time.Time{}
+}
+
+// metricsFailed records a failure to evaluate rules, and applies OnMetricsUnavailable
+// if metrics have been failing for long enough.
+func (a *Autoscaler) metricsFailed(ctx context.Context) error {
+	if a.consecutiveMetricFailures == 0 {
+		a.metricsFailingSince = a.Clock.Now()
+	}
+	a.consecutiveMetricFailures++
+	if a.OnMetricsUnavailable != MetricsUnavailableScaleToSafe || a.Clock.Now().Sub(a.metricsFailingSince) < a.MetricsUnavailableAfter {
+		return nil
+	}
+	current, sizes, err := a.getCurrentSize(ctx)
+	if err != nil {
+		return err
+	}
+	if sizes[current] == a.SafeSize {
+		return nil
+	}
+	a.Logger.Warn("metrics unavailable for too long, scaling to safe size",
+		slog.Time("since", a.metricsFailingSince), slog.Int("failures", a.consecutiveMetricFailures),
+		slog.String("current", sizes[current]), slog.String("target", a.SafeSize))
+	return a.doScale(ctx, scaleRequest{target: a.SafeSize, trigger: "metrics unavailable"})
+}
@@ -0,0 +1,96 @@
+package autoscaler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// PreScale scales the server up to Target ahead of a known event, and back to
+// its previous size once the event is over.
+type PreScale struct {
+	Name string `toml:"name" yaml:"name"`
+	// When the event starts.
+	Cron string `toml:"cron" yaml:"cron"`
+	// How long before the event to scale up.
+	Lead time.Duration `toml:"lead" yaml:"lead"`
+	// How long the event lasts; the server is scaled back down this long after it starts.
+	Duration time.Duration `toml:"duration" yaml:"duration"`
+	Target   string        `toml:"target" yaml:"target"`
+
+	a        *Autoscaler
+	ctx      context.Context
+	previous string
+}
+
+// shiftedSchedule fires offset after each activation of an underlying schedule.
+type shiftedSchedule struct {
+	inner  cron.Schedule
+	offset time.Duration
+}
+
+func (s shiftedSchedule) Next(t time.Time) time.Time {
+	return s.inner.Next(t.Add(-s.offset)).Add(s.offset)
+}
+
+func (a *Autoscaler) setupPreScales(ctx context.Context) {
+	for i := range a.PreScales {
+		p := &a.PreScales[i]
+		p.a = a
+		p.ctx = ctx
+		sched, err := cron.ParseStandard(p.Cron)
+		if err != nil {
+			a.Logger.Error("invalid pre-scale cron", slog.String("name", p.Name), slog.String("cron", p.Cron), slog.String("err", err.Error()))
+			continue
+		}
+		a.cron.Schedule(shiftedSchedule{sched, -p.Lead}, cron.FuncJob(p.scaleUp))
+		a.cron.Schedule(shiftedSchedule{sched, p.Duration}, cron.FuncJob(p.scaleDown))
+		slog.Debug("loaded pre-scale", slog.String("name", p.Name), slog.Any("preScale", p))
+	}
+}
+
+func (p *PreScale) scaleUp() {
+	logger := p.a.Logger.With(slog.String("preScale", p.Name))
+	current, sizes, err := p.a.getCurrentSize(p.ctx)
+	if err != nil {
+		logger.Error("failed to get current size", slog.String("err", err.Error()))
+		return
+	}
+	if sizes[current] == p.Target {
+		logger.Info("already at pre-scale target", slog.String("size", p.Target))
+		return
+	}
+	logger.Info("pre-scaling ahead of event", slog.String("current", sizes[current]), slog.String("target", p.Target))
+	err = p.a.doScale(p.ctx, scaleRequest{target: p.Target, trigger: "pre-scale: " + p.Name})
+	if err != nil {
+		logger.Error("failed to pre-scale", slog.String("err", err.Error()))
+		return
+	}
+	p.previous = sizes[current]
+}
+
+func (p *PreScale) scaleDown() {
+	logger := p.a.Logger.With(slog.String("preScale", p.Name))
+	if p.previous == "" {
+		logger.Debug("event over, but we didn't pre-scale for it")
+		return
+	}
+	previous := p.previous
+	p.previous = ""
+	current, sizes, err := p.a.getCurrentSize(p.ctx)
+	if err != nil {
+		logger.Error("failed to get current size", slog.String("err", err.Error()))
+		return
+	}
+	if sizes[current] != p.Target {
+		logger.Info("event over, but size has changed since pre-scaling; leaving it", slog.String("current", sizes[current]))
+		return
+	}
+	logger.Info("event over, scaling back down", slog.String("current", sizes[current]), slog.String("target", previous))
+	err = p.a.doScale(p.ctx, scaleRequest{target: previous, trigger: "pre-scale: " + p.Name})
+	if err != nil {
+		logger.Error("failed to scale back down", slog.String("err", err.Error()))
+	}
+}
@@ -0,0 +1,40 @@
+package autoscaler
+
+import "context"
+
+// Provider is a cloud provider that hosts the Minecraft server and can resize it.
+type Provider interface {
+	// Refresh re-fetches the provider's view of the server. Other methods may use
+	// state from the last Refresh rather than fetching it themselves.
+	Refresh(ctx context.Context) error
+	// GetCurrentSize returns the name of the server's current size.
+	GetCurrentSize(ctx context.Context) (string, error)
+	// GetAvailableSizes returns the sizes the server can be resized to, cheapest first.
+	GetAvailableSizes(ctx context.Context) ([]string, error)
+	// Placement returns the architecture and location that available sizes are restricted to.
+	Placement() (architecture, location string)
+	// GetPrice returns the hourly price of running the server at the given size.
+	GetPrice(ctx context.Context, size string) (float64, error)
+	StopServer(ctx context.Context) error
+	ResizeServer(ctx context.Context, size string) error
+}
+
+// ResizePreparer is implemented by providers that can get ready for a resize, e.g. by
+// checking or reserving the target size, while the server is still up.
+type ResizePreparer interface {
+	// PrepareResize is called while waiting for the server to empty before resizing it
+	// to size. If it fails, the server is not stopped.
+	PrepareResize(ctx context.Context, size string) error
+}
+
+// DiskUpgrader is implemented by providers whose sizes come with different amounts of
+// disk, and which can grow the server's disk when resizing it. Disk upgrades can't be
+// undone, so afterwards sizes with less disk than the server's are no longer available.
+type DiskUpgrader interface {
+	// DiskSize returns the disk space of the given size, in GB.
+	DiskSize(size string) (int, error)
+	// CurrentDiskSize returns the server's disk space as of the last Refresh, in GB.
+	CurrentDiskSize() int
+	// ResizeServerUpgradingDisk is like ResizeServer, but also grows the disk to the new size's.
+	ResizeServerUpgradingDisk(ctx context.Context, size string) error
+}
@@ -0,0 +1,68 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/markspolakovs/mcas/notify"
+)
+
+// ProviderHealth describes whether the cloud provider's API is reachable.
+type ProviderHealth struct {
+	Healthy      bool       `json:"healthy"`
+	FailingSince *time.Time `json:"failing_since,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	// Whether scaling is suspended because the provider has been unreachable for too long.
+	Holding bool `json:"holding"`
+}
+
+func (a *Autoscaler) ProviderHealth() ProviderHealth {
+	a.providerMux.Lock()
+	defer a.providerMux.Unlock()
+	h := ProviderHealth{Healthy: a.providerFailingSince.IsZero(), LastError: a.providerLastError, Holding: a.providerHolding}
+	if !h.Healthy {
+		since := a.providerFailingSince
+		h.FailingSince = &since
+	}
+	return h
+}
+
+func (a *Autoscaler) providerSucceeded(ctx context.Context) {
+	a.providerMux.Lock()
+	wasHolding, failingSince := a.providerHolding, a.providerFailingSince
+	a.providerFailingSince, a.providerLastError, a.providerHolding = time.Time{}, "", false
+	a.providerMux.Unlock()
+	if !failingSince.IsZero() {
+		a.Logger.Info("provider reachable again", slog.Time("failingSince", failingSince))
+	}
+	if wasHolding {
+		a.notify(ctx, notify.SeverityInfo, "Cloud provider is reachable again, resuming scaling.")
+	}
+}
+
+// providerFailed records a failure to reach the provider, and reports whether
+// scaling should hold because it has been unreachable for ProviderUnavailableAfter.
+func (a *Autoscaler) providerFailed(ctx context.Context, err error) bool {
+	a.providerMux.Lock()
+	now := a.Clock.Now()
+	if a.providerFailingSince.IsZero() {
+		a.providerFailingSince = now
+	}
+	a.providerLastError = err.Error()
+	since := a.providerFailingSince
+	startHolding := a.ProviderUnavailableAfter > 0 && !a.providerHolding && now.Sub(since) >= a.ProviderUnavailableAfter
+	if startHolding {
+		a.providerHolding = true
+	}
+	holding := a.providerHolding
+	a.providerMux.Unlock()
+	if startHolding {
+		a.notify(ctx, notify.SeverityError, fmt.Sprintf("Cloud provider unreachable since %s, not scaling until it's back: %s", since.Format(time.RFC3339), err))
+	}
+	if holding {
+		a.Logger.Error("provider unreachable, holding", slog.Time("since", since), slog.String("error", err.Error()))
+	}
+	return holding
+}
@@ -0,0 +1,73 @@
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+
+	mcnet "github.com/Tnze/go-mc/net"
+)
+
+var (
+	ErrRconAuthFailed        = errors.New("RCON authentication failed (check the password)")
+	ErrRconConnectionRefused = errors.New("RCON connection refused (check the address, and that RCON is enabled and the server is running)")
+	ErrRconTimeout           = errors.New("RCON connection timed out (check the address and firewall)")
+)
+
+const (
+	rconDialTimeout = 10 * time.Second
+	// How long a command may take if the context has no deadline of its own.
+	rconCommandTimeout = 30 * time.Second
+)
+
+// dialRCON is like mcnet.DialRCON, but distinguishes the common failure modes
+// so operators can tell a wrong password from a wrong address.
+func dialRCON(ctx context.Context, address, password string) (*mcnet.RCONConn, error) {
+	dialer := net.Dialer{Timeout: rconDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		err = classifyDialError(err)
+		slog.Warn("failed to connect to RCON", slog.String("address", address), slog.String("error", err.Error()))
+		return nil, err
+	}
+	c := &mcnet.RCONConn{Conn: conn, ReqID: rand.Int31()}
+	conn.SetDeadline(time.Now().Add(rconDialTimeout))
+	err = c.WritePacket(c.ReqID, 3, password)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send RCON login: %w", err)
+	}
+	respID, _, _, err := c.ReadPacket()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read RCON login response: %w", err)
+	}
+	conn.SetDeadline(time.Time{})
+	switch respID {
+	case c.ReqID:
+		return c, nil
+	case -1:
+		conn.Close()
+		slog.Warn("RCON login rejected", slog.String("address", address))
+		return nil, ErrRconAuthFailed
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("RCON login response ID mismatch: got %d, expected %d", respID, c.ReqID)
+	}
+}
+
+func classifyDialError(err error) error {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("%w: %w", ErrRconConnectionRefused, err)
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return fmt.Errorf("%w: %w", ErrRconTimeout, err)
+	}
+	return fmt.Errorf("failed to connect to RCON: %w", err)
+}
@@ -0,0 +1,62 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+)
+
+// updateRconMetric runs RconMetricCommand and records the value parsed from its
+// output, or forgets the previous value if that fails.
+func (a *Autoscaler) updateRconMetric(ctx context.Context) {
+	if a.RconMetricCommand == "" {
+		return
+	}
+	value, err := a.readRconMetric(ctx)
+	a.rconMetricMux.Lock()
+	defer a.rconMetricMux.Unlock()
+	if err != nil {
+		a.Logger.Warn("failed to read rcon metric", slog.String("name", a.RconMetricName), slog.String("error", err.Error()))
+		a.rconMetricValue = nil
+		return
+	}
+	slog.Debug("read rcon metric", slog.String("name", a.RconMetricName), slog.Float64("value", value))
+	a.rconMetricValue = &value
+	a.SelfMetrics.RconMetric.WithLabelValues(a.RconMetricName).Set(value)
+}
+
+func (a *Autoscaler) readRconMetric(ctx context.Context) (float64, error) {
+	out, err := a.Controller.Command(ctx, a.RconMetricCommand)
+	if err != nil {
+		return 0, fmt.Errorf("failed to run %q: %w", a.RconMetricCommand, err)
+	}
+	match := a.RconMetricRegex.FindStringSubmatch(out)
+	if match == nil {
+		return 0, fmt.Errorf("output of %q did not match %s: %q", a.RconMetricCommand, a.RconMetricRegex, out)
+	}
+	value, err := strconv.ParseFloat(match[len(match)-1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse rcon metric: %w", err)
+	}
+	return value, nil
+}
+
+func (a *Autoscaler) evaluateRconMetric(ctx context.Context, rule ScaleRule) (bool, error) {
+	if rule.RconMetric != a.RconMetricName {
+		return false, fmt.Errorf("unknown rcon metric %q", rule.RconMetric)
+	}
+	a.rconMetricMux.Lock()
+	value := a.rconMetricValue
+	a.rconMetricMux.Unlock()
+	if value == nil {
+		slog.Debug("no rcon metric value", slog.String("name", rule.RconMetric))
+		return false, nil
+	}
+	threshold, err := a.threshold(ctx, rule)
+	if err != nil {
+		return false, err
+	}
+	slog.Debug("evaluating rcon metric rule", slog.String("name", rule.RconMetric), slog.Float64("value", *value), slog.String("operator", rule.Operator), slog.Float64("threshold", threshold))
+	return compare(rule.Operator, *value, threshold)
+}
@@ -0,0 +1,39 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+const readyPollInterval = 5 * time.Second
+
+// waitForReady polls ReadyCommand until the server runs it successfully, or ReadyTimeout passes.
+func (a *Autoscaler) waitForReady(ctx context.Context) error {
+	if a.ReadyTimeout <= 0 {
+		return nil
+	}
+	defer a.Controller.Close()
+	command := a.ReadyCommand
+	if command == "" {
+		command = "list"
+	}
+	start := time.Now()
+	deadline := time.After(a.ReadyTimeout)
+	for {
+		_, err := a.Controller.Command(ctx, command)
+		if err == nil {
+			a.Logger.Info("server ready", slog.Duration("after", time.Since(start).Round(time.Second)))
+			return nil
+		}
+		slog.Debug("server not ready yet", slog.String("error", err.Error()))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline:
+			return fmt.Errorf("server did not answer %q within %s: %w", command, a.ReadyTimeout, err)
+		case <-time.After(readyPollInterval):
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"reflect"
+	"strings"
+
+	"github.com/markspolakovs/mcas/notify"
+)
+
+// RuleSet is everything that can be changed by reloading the rules file.
+type RuleSet struct {
+	Rules       []ScaleRule
+	Schedule    []ScaleSchedule
+	TimeWindows []TimeWindowRule
+	PreScales   []PreScale
+	Alerts      []AlertAction
+}
+
+// RuleSetDiff lists what changed between two RuleSets, identifying rules by
+// query and everything else by name.
+type RuleSetDiff struct {
+	Added    []string
+	Removed  []string
+	Modified []string
+}
+
+func (d RuleSetDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Modified) == 0
+}
+
+func (d RuleSetDiff) String() string {
+	var parts []string
+	for _, part := range []struct {
+		label string
+		items []string
+	}{{"added", d.Added}, {"removed", d.Removed}, {"modified", d.Modified}} {
+		if len(part.items) > 0 {
+			parts = append(parts, part.label+": "+strings.Join(part.items, ", "))
+		}
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// diffByKey compares old and new by key, using clean to drop unexported state before comparing.
+func diffByKey[T any](d *RuleSetDiff, kind string, old, new []T, key func(T) string, clean func(T) T) {
+	oldByKey := make(map[string]T, len(old))
+	for _, v := range old {
+		oldByKey[key(v)] = v
+	}
+	seen := make(map[string]bool, len(new))
+	for _, v := range new {
+		k := key(v)
+		seen[k] = true
+		prev, ok := oldByKey[k]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, fmt.Sprintf("%s %q", kind, k))
+		case !reflect.DeepEqual(clean(prev), clean(v)):
+			d.Modified = append(d.Modified, fmt.Sprintf("%s %q", kind, k))
+		}
+	}
+	for _, v := range old {
+		if k := key(v); !seen[k] {
+			d.Removed = append(d.Removed, fmt.Sprintf("%s %q", kind, k))
+		}
+	}
+}
+
+// DiffRuleSets reports what changed going from old to new.
+func DiffRuleSets(old, new RuleSet) RuleSetDiff {
+	var d RuleSetDiff
+	diffByKey(&d, "rule", old.Rules, new.Rules, func(r ScaleRule) string { return r.String() }, ScaleRule.withoutCompiled)
+	diffByKey(&d, "schedule", old.Schedule, new.Schedule, func(s ScaleSchedule) string { return s.DisplayName() }, func(s ScaleSchedule) ScaleSchedule {
+		s.a, s.ctx = nil, nil
+		return s
+	})
+	diffByKey(&d, "time window", old.TimeWindows, new.TimeWindows, func(w TimeWindowRule) string { return w.Name }, func(w TimeWindowRule) TimeWindowRule {
+		w.start, w.end, w.days = 0, 0, nil
+		return w
+	})
+	diffByKey(&d, "pre-scale", old.PreScales, new.PreScales, func(p PreScale) string { return p.Name }, func(p PreScale) PreScale {
+		p.a, p.ctx, p.previous = nil, nil, ""
+		return p
+	})
+	diffByKey(&d, "alert", old.Alerts, new.Alerts, func(a AlertAction) string { return a.Name }, func(a AlertAction) AlertAction { return a })
+	return d
+}
+
+// CurrentRules returns the rules, schedules, time windows and pre-scales in use.
+func (a *Autoscaler) CurrentRules() RuleSet {
+	a.rulesMux.RLock()
+	defer a.rulesMux.RUnlock()
+	return RuleSet{Rules: a.Rules, Schedule: a.Schedule, TimeWindows: a.TimeWindows, PreScales: a.PreScales, Alerts: a.Alerts}
+}
+
+// Reload replaces the rules, schedules, time windows and pre-scales, logging what changed.
+// Pre-scales that are mid-event forget the size they would have returned to.
+func (a *Autoscaler) Reload(ctx context.Context, rs RuleSet) RuleSetDiff {
+	diff := DiffRuleSets(a.CurrentRules(), rs)
+	a.Logger.Info("reloading rules", slog.Any("added", diff.Added), slog.Any("removed", diff.Removed), slog.Any("modified", diff.Modified))
+	if a.NotifyOnReload && !diff.Empty() {
+		a.notify(ctx, notify.SeverityInfo, "Rules reloaded: "+diff.String())
+	}
+	// Rules may have moved or been renamed, so start their metrics afresh.
+	a.SelfMetrics.RuleMet.Reset()
+	a.SelfMetrics.RuleEvalErrors.Reset()
+	a.rulesMux.Lock()
+	a.Rules, a.Schedule, a.TimeWindows, a.PreScales, a.Alerts = rs.Rules, rs.Schedule, rs.TimeWindows, rs.PreScales, rs.Alerts
+	old := a.cron
+	a.rulesMux.Unlock()
+	if old != nil {
+		// Let schedules that are running finish before the new ones can start.
+		<-old.Stop().Done()
+		if err := a.startSchedule(ctx); err != nil {
+			a.Logger.Error("failed to schedule all of the reloaded schedules", slog.String("error", err.Error()))
+		}
+	}
+	return diff
+}
@@ -0,0 +1,47 @@
+package autoscaler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestReloadReplacesSchedule(t *testing.T) {
+	a, _, _, _ := newTestAutoscaler(t, AutoScalerConfig{Schedule: []ScaleSchedule{{Name: "evening", Cron: "0 18 * * *", Action: 1}}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := a.SetupSchedule(ctx); err != nil {
+		t.Fatalf("SetupSchedule() error = %v", err)
+	}
+	first := a.cron
+
+	a.Reload(ctx, RuleSet{Schedule: []ScaleSchedule{
+		{Name: "morning", Cron: "0 8 * * *", Action: -1},
+		{Name: "evening", Cron: "0 18 * * *", Action: 1},
+	}})
+	a.rulesMux.RLock()
+	second := a.cron
+	a.rulesMux.RUnlock()
+	if second == first {
+		t.Fatal("Reload didn't replace the cron")
+	}
+	if got := len(second.Entries()); got != 2 {
+		t.Errorf("new cron has %d entries, want 2", got)
+	}
+}
+
+func TestSetupScheduleRejectsInvalidCron(t *testing.T) {
+	a, _, _, _ := newTestAutoscaler(t, AutoScalerConfig{Schedule: []ScaleSchedule{
+		{Name: "evening", Cron: "0 18 * * *", Action: 1},
+		{Name: "typo", Cron: "0 18 * * funday", Action: -1},
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := a.SetupSchedule(ctx); err == nil {
+		t.Fatal("SetupSchedule() succeeded, want an error for the invalid cron")
+	}
+	a.rulesMux.RLock()
+	defer a.rulesMux.RUnlock()
+	if got := len(a.cron.Entries()); got != 1 {
+		t.Errorf("cron has %d entries, want the 1 valid schedule", got)
+	}
+}
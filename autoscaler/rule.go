@@ -2,47 +2,792 @@ package autoscaler
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
 
+	"github.com/markspolakovs/mcas/metrics"
+	"github.com/markspolakovs/mcas/notify"
 	"github.com/prometheus/common/model"
 )
 
 type ScaleRule struct {
-	Query  string `toml:"query"`
-	Action int    `toml:"action"`
+	// Identifies the rule in metrics (default: its position in the rules).
+	Name string `toml:"name" yaml:"name"`
+	// Defaults to true. Disabled rules are kept in config but never evaluated.
+	Enabled *bool  `toml:"enabled" yaml:"enabled"`
+	Query   string `toml:"query" yaml:"query"`
+	Action  int    `toml:"action" yaml:"action"`
+	// If set, the query must have returned results continuously for this long
+	// (in the same way as the "for" clause of a Prometheus alerting rule).
+	For time.Duration `toml:"for" yaml:"for"`
+	// If set, the rule is met when the query's value compares to Threshold
+	// using Operator (e.g. "<" to scale up when TPS drops below a threshold),
+	// rather than when the query returns any results.
+	Operator  string  `toml:"operator" yaml:"operator"`
+	Threshold float64 `toml:"threshold" yaml:"threshold"`
+	// If set, the threshold is instead ThresholdRatio (default 1) times the value of
+	// this query, e.g. the server's max players.
+	ThresholdQuery string  `toml:"threshold_query" yaml:"threshold_query"`
+	ThresholdRatio float64 `toml:"threshold_ratio" yaml:"threshold_ratio"`
+	// If the query's value also compares to PanicThreshold using Operator, scale
+	// straight to PanicTargetSize (default: the largest allowed size), ignoring the cooldown.
+	PanicThreshold     *float64 `toml:"panic_threshold" yaml:"panic_threshold"`
+	PanicTargetSize    string   `toml:"panic_target_size" yaml:"panic_target_size"`
+	PanicSkipEmptyWait bool     `toml:"panic_skip_empty_wait" yaml:"panic_skip_empty_wait"`
+	// If set, the rule is ignored if the last scale was in this direction (1 for up, -1 for down)
+	// and happened within the last NotAfterWindow.
+	NotAfterDirection int           `toml:"not_after_direction" yaml:"not_after_direction"`
+	NotAfterWindow    time.Duration `toml:"not_after_window" yaml:"not_after_window"`
+	// If set, the query returns a (current or forecast) player count, and the rule scales
+	// to the smallest size whose SizeCapacity covers it instead of by Action.
+	FitToPlayers bool `toml:"fit_to_players" yaml:"fit_to_players"`
+	// If set, the query is evaluated over the last Window at the given Step (default 30s),
+	// each series is reduced with Aggregation (avg, min, max or last, default avg), and
+	// the rule is met if any result compares to the threshold using Operator.
+	// For very long windows, consider querying a recording rule.
+	Window      time.Duration `toml:"window" yaml:"window"`
+	Step        time.Duration `toml:"step" yaml:"step"`
+	Aggregation string        `toml:"aggregation" yaml:"aggregation"`
+	// If set, the rule is only met if this query also returns no results, or, if
+	// GuardOperator is set, its value compares to GuardThreshold using GuardOperator.
+	// For example, to not scale down while a backup is running.
+	GuardQuery     string  `toml:"guard_query" yaml:"guard_query"`
+	GuardOperator  string  `toml:"guard_operator" yaml:"guard_operator"`
+	GuardThreshold float64 `toml:"guard_threshold" yaml:"guard_threshold"`
+	// If set, the rule compares the latest value of this RCON metric (see
+	// AutoScalerConfig.RconMetricName) to the threshold instead of querying Prometheus.
+	RconMetric string `toml:"rcon_metric" yaml:"rcon_metric"`
+	// If set, the rule is met based on several conditions instead of its own query.
+	Composite *CompositeRule `toml:"composite" yaml:"composite"`
+	// If set, the rule scales to the next larger size with more disk than the server
+	// has, growing its disk, instead of by Action. This can't be undone: the server can
+	// never again be resized to a size with less disk.
+	UpgradeDisk bool `toml:"upgrade_disk" yaml:"upgrade_disk"`
+	// If set, the rule's scales bypass the minimum time between scaling actions.
+	// Meant for emergency rules with a high threshold, e.g. when the server is melting.
+	IgnoreCooldown bool `toml:"ignore_cooldown" yaml:"ignore_cooldown"`
+
+	query          *template.Template
+	thresholdQuery *template.Template
+	guardQuery     *template.Template
+}
+
+// CompositeRule combines several conditions into one rule, so that e.g. "players
+// high or TPS low" gives a single, coherent action. Only the conditions' queries,
+// thresholds and so on are used, not their actions.
+type CompositeRule struct {
+	// Either "all" or "any" (the default) of the conditions must be met.
+	Match      string      `toml:"match" yaml:"match"`
+	Conditions []ScaleRule `toml:"conditions" yaml:"conditions"`
+}
+
+// id returns the rule's Name, or its index in the rules if it has none.
+func (r ScaleRule) id(index int) string {
+	if r.Name != "" {
+		return r.Name
+	}
+	return strconv.Itoa(index)
+}
+
+func (r ScaleRule) IsEnabled() bool {
+	return r.Enabled == nil || *r.Enabled
+}
+
+// String describes the rule for logs and scale events: its query and threshold, or its conditions.
+func (r ScaleRule) String() string {
+	switch {
+	case r.Composite != nil:
+		conditions := make([]string, len(r.Composite.Conditions))
+		for i, c := range r.Composite.Conditions {
+			conditions[i] = c.String()
+		}
+		match := r.Composite.Match
+		if match == "" {
+			match = "any"
+		}
+		return fmt.Sprintf("%s(%s)", match, strings.Join(conditions, ", "))
+	}
+	query := r.Query
+	if r.RconMetric != "" {
+		query = "rcon_metric " + r.RconMetric
+	}
+	switch {
+	case r.Operator == "":
+		return query
+	case r.ThresholdQuery != "":
+		return fmt.Sprintf("%s %s (%s)", query, r.Operator, r.ThresholdQuery)
+	}
+	return fmt.Sprintf("%s %s %g", query, r.Operator, r.Threshold)
+}
+
+// withoutCompiled returns the rule without its compiled templates, for comparison.
+func (r ScaleRule) withoutCompiled() ScaleRule {
+	r.query, r.thresholdQuery, r.guardQuery = nil, nil, nil
+	if r.Composite != nil {
+		c := *r.Composite
+		c.Conditions = make([]ScaleRule, len(r.Composite.Conditions))
+		for i, cond := range r.Composite.Conditions {
+			c.Conditions[i] = cond.withoutCompiled()
+		}
+		r.Composite = &c
+	}
+	return r
+}
+
+// QueryContext is the data available to templates in rule queries, e.g. {{.Server}}.
+type QueryContext struct {
+	Server string
+}
+
+// Compile parses the rule's query template and checks that it can be rendered.
+func (r *ScaleRule) Compile() error {
+	tmpl, err := template.New("query").Option("missingkey=error").Parse(r.Query)
+	if err != nil {
+		return fmt.Errorf("failed to parse query template %q: %w", r.Query, err)
+	}
+	if err := tmpl.Execute(&strings.Builder{}, QueryContext{}); err != nil {
+		return fmt.Errorf("failed to render query template %q: %w", r.Query, err)
+	}
+	if r.Operator != "" {
+		if _, err := compare(r.Operator, 0, 0); err != nil {
+			return err
+		}
+	}
+	if r.PanicThreshold != nil && r.Operator == "" {
+		return fmt.Errorf("panic_threshold requires an operator")
+	}
+	if r.ThresholdQuery != "" {
+		if r.Operator == "" {
+			return fmt.Errorf("threshold_query requires an operator")
+		}
+		r.thresholdQuery, err = template.New("threshold_query").Option("missingkey=error").Parse(r.ThresholdQuery)
+		if err != nil {
+			return fmt.Errorf("failed to parse threshold query template %q: %w", r.ThresholdQuery, err)
+		}
+		if err := r.thresholdQuery.Execute(&strings.Builder{}, QueryContext{}); err != nil {
+			return fmt.Errorf("failed to render threshold query template %q: %w", r.ThresholdQuery, err)
+		}
+	}
+	if r.Composite != nil {
+		if err := r.Composite.compile(); err != nil {
+			return err
+		}
+		if r.Query != "" || r.RconMetric != "" || r.Operator != "" || r.For > 0 || r.Window > 0 || r.FitToPlayers || r.PanicThreshold != nil {
+			return fmt.Errorf("composite can't be combined with query, rcon_metric, operator, for, window, fit_to_players or panic_threshold")
+		}
+	}
+	if r.RconMetric != "" && (r.Operator == "" || r.For > 0 || r.Window > 0 || r.FitToPlayers || r.PanicThreshold != nil) {
+		return fmt.Errorf("rcon_metric requires an operator, and can't be combined with for, window, fit_to_players or panic_threshold")
+	}
+	if r.GuardQuery != "" {
+		r.guardQuery, err = template.New("guard_query").Option("missingkey=error").Parse(r.GuardQuery)
+		if err != nil {
+			return fmt.Errorf("failed to parse guard query template %q: %w", r.GuardQuery, err)
+		}
+		if err := r.guardQuery.Execute(&strings.Builder{}, QueryContext{}); err != nil {
+			return fmt.Errorf("failed to render guard query template %q: %w", r.GuardQuery, err)
+		}
+		if r.GuardOperator != "" {
+			if _, err := compare(r.GuardOperator, 0, 0); err != nil {
+				return err
+			}
+		}
+	} else if r.GuardOperator != "" {
+		return fmt.Errorf("guard_operator requires a guard_query")
+	}
+	if r.UpgradeDisk && (r.FitToPlayers || r.PanicThreshold != nil) {
+		return fmt.Errorf("upgrade_disk can't be combined with fit_to_players or panic_threshold")
+	}
+	if r.FitToPlayers && (r.Operator != "" || r.For > 0 || r.PanicThreshold != nil) {
+		return fmt.Errorf("fit_to_players can't be combined with operator, for or panic_threshold")
+	}
+	if r.Window > 0 {
+		if r.Operator == "" {
+			return fmt.Errorf("window requires an operator")
+		}
+		if r.For > 0 || r.FitToPlayers {
+			return fmt.Errorf("window can't be combined with for or fit_to_players")
+		}
+		if _, err := aggregate(r.Aggregation, []model.SamplePair{{}}); err != nil {
+			return err
+		}
+	} else if r.Step > 0 || r.Aggregation != "" {
+		return fmt.Errorf("step and aggregation require a window")
+	}
+	if (r.NotAfterDirection == 0) != (r.NotAfterWindow == 0) {
+		return fmt.Errorf("not_after_direction and not_after_window must be set together")
+	}
+	r.query = tmpl
+	return nil
+}
+
+func (c *CompositeRule) compile() error {
+	if c.Match != "" && c.Match != "all" && c.Match != "any" {
+		return fmt.Errorf("invalid composite match %q, must be all or any", c.Match)
+	}
+	if len(c.Conditions) == 0 {
+		return fmt.Errorf("composite rule has no conditions")
+	}
+	for i := range c.Conditions {
+		if c.Conditions[i].FitToPlayers || c.Conditions[i].PanicThreshold != nil || c.Conditions[i].UpgradeDisk {
+			return fmt.Errorf("composite condition %d: fit_to_players, panic_threshold and upgrade_disk can't be used in conditions", i)
+		}
+		if err := c.Conditions[i].Compile(); err != nil {
+			return fmt.Errorf("composite condition %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// evaluateComposite evaluates every condition, then combines the results.
+func (a *Autoscaler) evaluateComposite(ctx context.Context, rule ScaleRule) (bool, error) {
+	all := rule.Composite.Match == "all"
+	met := all
+	for _, c := range rule.Composite.Conditions {
+		res, err := a.EvaluateRule(ctx, c)
+		if err != nil {
+			return false, err
+		}
+		if res {
+			res, err = a.guardClear(ctx, c)
+			if err != nil {
+				return false, err
+			}
+		}
+		slog.Debug("evaluated composite condition", slog.String("condition", c.String()), slog.Bool("met", res))
+		if all {
+			met = met && res
+		} else {
+			met = met || res
+		}
+	}
+	return met, nil
+}
+
+// suppressedByRecentScale reports whether the rule is ignored because of its NotAfter* settings.
+func (a *Autoscaler) suppressedByRecentScale(rule ScaleRule) bool {
+	lastScaledAt, lastDirection := a.lastScale()
+	if rule.NotAfterDirection == 0 || lastScaledAt.IsZero() {
+		return false
+	}
+	sameDirection := (rule.NotAfterDirection > 0 && lastDirection > 0) || (rule.NotAfterDirection < 0 && lastDirection < 0)
+	return sameDirection && a.Clock.Now().Sub(lastScaledAt) < rule.NotAfterWindow
+}
+
+func compare(op string, value, threshold float64) (bool, error) {
+	switch op {
+	case ">":
+		return value > threshold, nil
+	case "<":
+		return value < threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	case "==", "=":
+		return value == threshold, nil
+	case "!=":
+		return value != threshold, nil
+	}
+	return false, fmt.Errorf("invalid operator %q", op)
+}
+
+func (a *Autoscaler) renderQuery(rule *ScaleRule) (string, error) {
+	if rule.query == nil {
+		if err := rule.Compile(); err != nil {
+			return "", err
+		}
+	}
+	return a.render(rule.query, rule.Query)
+}
+
+func (a *Autoscaler) render(tmpl *template.Template, source string) (string, error) {
+	var sb strings.Builder
+	err := tmpl.Execute(&sb, QueryContext{
+		Server: a.ServerName,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to render query template %q: %w", source, err)
+	}
+	return sb.String(), nil
+}
+
+// threshold returns the value the rule's query is compared against.
+func (a *Autoscaler) threshold(ctx context.Context, rule ScaleRule) (float64, error) {
+	if rule.thresholdQuery == nil {
+		return rule.Threshold, nil
+	}
+	query, err := a.render(rule.thresholdQuery, rule.ThresholdQuery)
+	if err != nil {
+		return 0, err
+	}
+	value, err := a.Metrics.QueryScalar(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query threshold for rule %q: %w", rule.Query, err)
+	}
+	ratio := rule.ThresholdRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	return value * ratio, nil
+}
+
+// guardClear reports whether the rule's guard query allows it to act.
+func (a *Autoscaler) guardClear(ctx context.Context, rule ScaleRule) (bool, error) {
+	if rule.GuardQuery == "" {
+		return true, nil
+	}
+	if rule.guardQuery == nil {
+		if err := rule.Compile(); err != nil {
+			return false, err
+		}
+	}
+	query, err := a.render(rule.guardQuery, rule.GuardQuery)
+	if err != nil {
+		return false, err
+	}
+	if rule.GuardOperator != "" {
+		value, err := a.Metrics.QueryScalar(ctx, query)
+		if errors.Is(err, metrics.ErrNoData) {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to query guard for rule %q: %w", rule.Query, err)
+		}
+		return compare(rule.GuardOperator, value, rule.GuardThreshold)
+	}
+	r, err := a.Metrics.Query(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("failed to query guard for rule %q: %w", rule.Query, err)
+	}
+	met, err := resultMet(r)
+	return !met, err
 }
 
 func (a *Autoscaler) EvaluateRule(ctx context.Context, rule ScaleRule) (bool, error) {
-	r, err := a.Metrics.Query(ctx, rule.Query)
+	query, err := a.renderQuery(&rule)
+	if err != nil {
+		return false, err
+	}
+	if rule.Composite != nil {
+		return a.evaluateComposite(ctx, rule)
+	}
+	if rule.RconMetric != "" {
+		return a.evaluateRconMetric(ctx, rule)
+	}
+	if rule.For > 0 {
+		return a.evaluateRuleFor(ctx, rule, query)
+	}
+	if rule.Window > 0 {
+		return a.evaluateWindow(ctx, rule, query)
+	}
+	if rule.Operator != "" {
+		return a.evaluateThreshold(ctx, rule, query)
+	}
+	r, err := a.Metrics.Query(ctx, query)
+	if err != nil {
+		return false, fmt.Errorf("failed to query for rule %q: %w", rule.Query, err)
+	}
+	slog.Debug("evaluating rule", slog.String("query", query), slog.Any("result", r))
+	return resultMet(r)
+}
+
+func (a *Autoscaler) evaluateThreshold(ctx context.Context, rule ScaleRule, query string) (bool, error) {
+	value, err := a.Metrics.QueryScalar(ctx, query)
+	if errors.Is(err, metrics.ErrNoData) {
+		slog.Debug("rule query returned no data", slog.String("query", query))
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query for rule %q: %w", rule.Query, err)
+	}
+	threshold, err := a.threshold(ctx, rule)
+	if err != nil {
+		return false, err
+	}
+	slog.Debug("evaluating threshold rule", slog.String("query", query), slog.Float64("value", value), slog.String("operator", rule.Operator), slog.Float64("threshold", threshold))
+	return compare(rule.Operator, value, threshold)
+}
+
+func (a *Autoscaler) evaluatePanic(ctx context.Context, rule ScaleRule) (bool, error) {
+	query, err := a.renderQuery(&rule)
+	if err != nil {
+		return false, err
+	}
+	value, err := a.Metrics.QueryScalar(ctx, query)
+	if errors.Is(err, metrics.ErrNoData) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to query for rule %q: %w", rule.Query, err)
+	}
+	return compare(rule.Operator, value, *rule.PanicThreshold)
+}
+
+func (a *Autoscaler) panicScale(ctx context.Context, rule ScaleRule) error {
+	current, sizes, err := a.getCurrentSize(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current size: %w", err)
+	}
+	target := rule.PanicTargetSize
+	if target == "" {
+		target = sizes[len(sizes)-1]
+	}
+	a.Logger.Error("PANIC SCALE: rule breached its panic threshold", slog.String("rule", rule.String()), slog.Float64("panicThreshold", *rule.PanicThreshold), slog.String("current", sizes[current]), slog.String("target", target))
+	if sizes[current] == target {
+		a.Logger.Info("already at panic target size", slog.String("size", target))
+		return nil
+	}
+	return a.doScale(ctx, scaleRequest{
+		target:         target,
+		ignoreCooldown: true,
+		skipEmptyWait:  rule.PanicSkipEmptyWait,
+		trigger:        "panic: " + rule.String(),
+	})
+}
+
+// resultMet reports whether a query result counts as the rule being met: a non-empty
+// vector, a scalar other than 0 or NaN (so bool comparisons like scalar(x) > bool 5
+// work), or a matrix where any series' latest sample is non-NaN.
+func resultMet(r model.Value) (bool, error) {
+	switch v := r.(type) {
+	case model.Vector:
+		return len(v) > 0, nil
+	case *model.Scalar:
+		return v.Value != 0 && !math.IsNaN(float64(v.Value)), nil
+	case model.Matrix:
+		for _, series := range v {
+			if len(series.Values) == 0 {
+				continue
+			}
+			if !math.IsNaN(float64(series.Values[len(series.Values)-1].Value)) {
+				return true, nil
+			}
+		}
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported result type %T", r)
+	}
+}
+
+func (a *Autoscaler) evaluateRuleFor(ctx context.Context, rule ScaleRule, query string) (bool, error) {
+	end := a.Clock.Now()
+	start := end.Add(-rule.For)
+	m, step, err := a.Metrics.QueryRange(ctx, query, start, end, 0)
+	if err != nil {
+		return false, fmt.Errorf("failed to query for rule %q: %w", rule.Query, err)
+	}
+	slog.Debug("evaluating rule over range", slog.String("query", query), slog.Duration("for", rule.For), slog.Int("series", len(m)))
+	var threshold float64
+	if rule.Operator != "" {
+		threshold, err = a.threshold(ctx, rule)
+		if err != nil {
+			return false, err
+		}
+	}
+	for _, series := range m {
+		values := series.Values
+		if rule.Operator != "" {
+			values = slices.DeleteFunc(slices.Clone(values), func(v model.SamplePair) bool {
+				ok, _ := compare(rule.Operator, float64(v.Value), threshold)
+				return !ok
+			})
+		}
+		if firingThroughout(values, start, end, step) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (a *Autoscaler) evaluateWindow(ctx context.Context, rule ScaleRule, query string) (bool, error) {
+	end := a.Clock.Now()
+	m, _, err := a.Metrics.QueryRange(ctx, query, end.Add(-rule.Window), end, rule.Step)
 	if err != nil {
 		return false, fmt.Errorf("failed to query for rule %q: %w", rule.Query, err)
 	}
-	v, ok := r.(model.Vector)
-	if !ok {
-		return false, fmt.Errorf("expected vector result, got %T", r)
+	threshold, err := a.threshold(ctx, rule)
+	if err != nil {
+		return false, err
+	}
+	for _, series := range m {
+		if len(series.Values) == 0 {
+			continue
+		}
+		value, err := aggregate(rule.Aggregation, series.Values)
+		if err != nil {
+			return false, err
+		}
+		slog.Debug("evaluating rule over window", slog.String("query", query), slog.Duration("window", rule.Window), slog.String("series", series.Metric.String()), slog.Float64("value", value), slog.Float64("threshold", threshold))
+		if ok, err := compare(rule.Operator, value, threshold); ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// aggregate reduces a series' samples to a single value.
+func aggregate(aggregation string, values []model.SamplePair) (float64, error) {
+	switch aggregation {
+	case "", "avg":
+		var sum float64
+		for _, v := range values {
+			sum += float64(v.Value)
+		}
+		return sum / float64(len(values)), nil
+	case "min":
+		rv := math.Inf(1)
+		for _, v := range values {
+			rv = math.Min(rv, float64(v.Value))
+		}
+		return rv, nil
+	case "max":
+		rv := math.Inf(-1)
+		for _, v := range values {
+			rv = math.Max(rv, float64(v.Value))
+		}
+		return rv, nil
+	case "last":
+		return float64(values[len(values)-1].Value), nil
 	}
-	slog.Debug("evaluating rule", slog.String("query", rule.Query), slog.Any("result", v))
-	return len(v) > 0, nil
+	return 0, fmt.Errorf("invalid aggregation %q", aggregation)
+}
+
+// firingThroughout reports whether values covers the whole of [start, end]
+// without any gaps larger than step.
+func firingThroughout(values []model.SamplePair, start, end time.Time, step time.Duration) bool {
+	if len(values) == 0 {
+		return false
+	}
+	if values[0].Timestamp.Time().After(start.Add(step)) {
+		return false
+	}
+	if values[len(values)-1].Timestamp.Time().Before(end.Add(-step)) {
+		return false
+	}
+	for i := 1; i < len(values); i++ {
+		if values[i].Timestamp.Sub(values[i-1].Timestamp) > step {
+			return false
+		}
+	}
+	return true
+}
+
+// scaleDownStepped scales down by the rule's action one size at a time, waiting
+// ScaleDownStepSettle after each step and only carrying on if the rule (and idle
+// check, if any) is still met, in case the metrics were lagging behind activity.
+func (a *Autoscaler) scaleDownStepped(ctx context.Context, rule ScaleRule) error {
+	steps := -rule.Action
+	for step := 1; step <= steps; step++ {
+		if step > 1 {
+			a.Logger.Info("waiting for the server to settle before the next step down", slog.Int("step", step), slog.Duration("settle", a.ScaleDownStepSettle))
+			if err := a.Clock.Sleep(ctx, a.ScaleDownStepSettle); err != nil {
+				return err
+			}
+			a.Metrics.InvalidateCache()
+			a.updateEmptySince(ctx)
+			met, err := a.EvaluateRule(ctx, rule)
+			if err == nil && met {
+				met, err = a.guardClear(ctx, rule)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to re-check rule between steps: %w", err)
+			}
+			if !met {
+				a.Logger.Info("rule no longer met, abandoning the remaining steps down", slog.String("rule", rule.String()), slog.Int("step", step), slog.Int("steps", steps))
+				return nil
+			}
+		}
+		ok, _, err := a.CanScale(ctx, -1)
+		if err != nil {
+			return fmt.Errorf("failed to check if can scale: %w", err)
+		}
+		if !ok {
+			return nil
+		}
+		// The cooldown is for separate decisions, not the steps of this one.
+		err = a.doScale(ctx, scaleRequest{direction: -1, ignoreCooldown: step > 1 || rule.IgnoreCooldown, trigger: fmt.Sprintf("rule (step %d of %d): %s", step, steps, rule)})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyClamped tells the operator that a rule is met but can't act because of the
+// size limits, which otherwise goes unnoticed if it happens every loop.
+func (a *Autoscaler) notifyClamped(ctx context.Context, rule ScaleRule, reason Reason) {
+	if a.NotifyClampedEvery <= 0 {
+		return
+	}
+	key := rule.String() + "\x00" + string(reason)
+	a.clampedMux.Lock()
+	if last, ok := a.clampedNotified[key]; ok && a.Clock.Now().Sub(last) < a.NotifyClampedEvery {
+		a.clampedMux.Unlock()
+		return
+	}
+	if a.clampedNotified == nil {
+		a.clampedNotified = make(map[string]time.Time)
+	}
+	a.clampedNotified[key] = a.Clock.Now()
+	a.clampedMux.Unlock()
+
+	current, err := a.Scaler.GetCurrentSize(ctx)
+	if err != nil {
+		current = "unknown"
+	}
+	direction, limit := "up", "max"
+	if reason == ReasonAtMin {
+		direction, limit = "down", "min"
+	}
+	a.notify(ctx, notify.SeverityWarning, fmt.Sprintf("Scale-%s rule %q is met but the server is already at its %s size %s.", direction, rule.String(), limit, current))
 }
 
 func (a *Autoscaler) CoreLoop(ctx context.Context) error {
-	for _, rule := range a.Rules {
-		res, err := a.EvaluateRule(ctx, rule)
+	a.updateSelfMetrics()
+	a.Metrics.InvalidateCache()
+	if err := a.Scaler.Refresh(ctx); err != nil {
+		if a.providerFailed(ctx, err) {
+			return nil
+		}
+		return fmt.Errorf("failed to refresh server state: %w", err)
+	}
+	a.providerSucceeded(ctx)
+	a.updateEmptySince(ctx)
+	a.updateRconMetric(ctx)
+	if err := a.updatePriceMetric(ctx); err != nil {
+		a.Logger.Warn("failed to update price metric", slog.String("error", err.Error()))
+	}
+	if maintenance, err := a.inMaintenance(ctx); err != nil || maintenance {
+		if maintenance {
+			a.Logger.Info("maintenance mode active, skipping rules", slog.String("query", a.MaintenanceQuery))
+		}
+		// The maintenance query is often the first to notice Prometheus is down.
+		if errors.Is(err, ErrMetricsUnavailable) {
+			if scaleErr := a.metricsFailed(ctx); scaleErr != nil {
+				a.Logger.Error("failed to scale to safe size", slog.String("error", scaleErr.Error()))
+			}
+		}
+		return err
+	}
+	if until := a.pinnedUntil(); !until.IsZero() {
+		a.Logger.Info("pinned, skipping rules", slog.Time("until", until))
+		return a.enforcePin(ctx)
+	}
+	if active, err := a.enforceTimeWindows(ctx); active {
+		return err
+	}
+	if active, err := a.enforceCalendar(ctx); active {
+		return err
+	}
+	// A pin, time window or calendar event already decides the size, and warmup
+	// means the size was only just changed.
+	if warmup, _ := a.inWarmup(); !warmup {
+		if err := a.reconcileUnknownSize(ctx); err != nil {
+			return fmt.Errorf("failed to reconcile current size: %w", err)
+		}
+	}
+	metricsOK := false
+	for i, rule := range a.CurrentRules().Rules {
+		if !rule.IsEnabled() {
+			continue
+		}
+		id := rule.id(i)
+		var res bool
+		var fitTarget string
+		var err error
+		if rule.FitToPlayers {
+			fitTarget, err = a.fitToPlayers(ctx, rule)
+			res = fitTarget != ""
+		} else {
+			res, err = a.EvaluateRule(ctx, rule)
+		}
+		if err == nil && res {
+			var clear bool
+			clear, err = a.guardClear(ctx, rule)
+			if err == nil && !clear {
+				a.Logger.Info("rule met but guard query is not clear", slog.String("rule", rule.String()), slog.String("guardQuery", rule.GuardQuery))
+				res = false
+			}
+		}
 		if err != nil {
+			a.SelfMetrics.RuleEvalErrors.WithLabelValues(id).Inc()
+			// An invalid rule says nothing about whether Prometheus is up.
+			if errors.Is(err, ErrMetricsUnavailable) {
+				if scaleErr := a.metricsFailed(ctx); scaleErr != nil {
+					a.Logger.Error("failed to scale to safe size", slog.String("error", scaleErr.Error()))
+				}
+			}
 			return fmt.Errorf("failed to evaluate rule: %w", err)
 		}
+		if !metricsOK {
+			a.metricsSucceeded()
+			metricsOK = true
+		}
+		if res {
+			a.SelfMetrics.RuleMet.WithLabelValues(id).Set(1)
+		} else {
+			a.SelfMetrics.RuleMet.WithLabelValues(id).Set(0)
+		}
 		if !res {
-			slog.Debug("rule not met", slog.String("query", rule.Query))
+			slog.Debug("rule not met", slog.String("rule", rule.String()))
 			continue
 		}
-		slog.Info("rule met", slog.String("query", rule.Query), slog.Int("action", rule.Action))
-		ok, err := a.CanScale(ctx, rule.Action)
+		slog.Info("rule met", slog.String("rule", rule.String()), slog.Int("action", rule.Action))
+		if a.suppressedByRecentScale(rule) {
+			lastScaledAt, lastDirection := a.lastScale()
+			a.Logger.Info("rule suppressed by recent scale", slog.String("rule", rule.String()), slog.Int("lastDirection", lastDirection), slog.Time("lastScaledAt", lastScaledAt))
+			continue
+		}
+		if warmup, until := a.inWarmup(); warmup {
+			a.Logger.Info("in warmup, not acting on rule", slog.String("rule", rule.String()), slog.Time("until", until))
+			return nil
+		}
+		if rule.FitToPlayers {
+			return a.doScale(ctx, scaleRequest{target: fitTarget, ignoreCooldown: rule.IgnoreCooldown, idleHold: true, trigger: "fit to players: " + rule.String()})
+		}
+		if rule.UpgradeDisk {
+			target, err := a.diskUpgradeTarget(ctx)
+			if err != nil {
+				return fmt.Errorf("failed to find size to upgrade disk to: %w", err)
+			}
+			if target == "" {
+				a.notifyClamped(ctx, rule, ReasonAtMax)
+				return nil
+			}
+			return a.doScale(ctx, scaleRequest{target: target, upgradeDisk: true, ignoreCooldown: rule.IgnoreCooldown, trigger: "disk upgrade: " + rule.String()})
+		}
+		if rule.PanicThreshold != nil {
+			panicking, err := a.evaluatePanic(ctx, rule)
+			if err != nil {
+				return fmt.Errorf("failed to evaluate panic threshold: %w", err)
+			}
+			if panicking {
+				return a.panicScale(ctx, rule)
+			}
+		}
+		if rule.Action < 0 && a.IdleScaleToCheapest && a.idle() {
+			return a.scaleToCheapest(ctx, "rule (idle): "+rule.String())
+		}
+		if rule.Action < -1 && a.ScaleDownStepSettle > 0 {
+			return a.scaleDownStepped(ctx, rule)
+		}
+		ok, reason, err := a.CanScale(ctx, rule.Action)
 		if err != nil {
 			return fmt.Errorf("failed to check if can scale: %w", err)
 		}
+		if reason == ReasonAtMax || reason == ReasonAtMin {
+			a.notifyClamped(ctx, rule, reason)
+		}
 		if ok {
-			return a.DoScale(ctx, rule.Action)
+			return a.doScale(ctx, scaleRequest{direction: rule.Action, ignoreCooldown: rule.IgnoreCooldown, trigger: "rule: " + rule.String()})
 		} else {
 			return nil
 		}
@@ -0,0 +1,193 @@
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/prometheus/common/model"
+)
+
+func TestCoreLoop(t *testing.T) {
+	errQuery := errors.New("prometheus is down")
+	tests := []struct {
+		name        string
+		rules       []ScaleRule
+		size        string
+		values      map[string]model.Value
+		errs        map[string]error
+		scaling     bool
+		wantErr     bool
+		wantResizes []string
+	}{
+		{
+			name:   "no rules met",
+			rules:  []ScaleRule{{Query: "players > 10", Action: 1}, {Query: "players < 1", Action: -1}},
+			values: map[string]model.Value{},
+		},
+		{
+			name:        "one rule met and scalable",
+			rules:       []ScaleRule{{Query: "players > 10", Action: 1}},
+			values:      map[string]model.Value{"players > 10": vector(12)},
+			wantResizes: []string{"cax31"},
+		},
+		{
+			name:        "scale-down rule met",
+			rules:       []ScaleRule{{Query: "players > 10", Action: 1}, {Query: "players < 1", Action: -1}},
+			values:      map[string]model.Value{"players < 1": vector(0)},
+			wantResizes: []string{"cax11"},
+		},
+		{
+			name:    "scale-down abandoned when a scale-up rule is also met",
+			rules:   []ScaleRule{{Query: "players < 1", Action: -1}, {Query: "players > 10", Action: 1}},
+			values:  map[string]model.Value{"players < 1": vector(0), "players > 10": vector(12)},
+			wantErr: true,
+		},
+		{
+			name:   "one rule met but at max",
+			rules:  []ScaleRule{{Query: "players > 10", Action: 1}},
+			size:   "cax41",
+			values: map[string]model.Value{"players > 10": vector(12)},
+		},
+		{
+			name:    "scaling already in progress",
+			rules:   []ScaleRule{{Query: "players > 10", Action: 1}},
+			values:  map[string]model.Value{"players > 10": vector(12)},
+			scaling: true,
+			wantErr: true,
+		},
+		{
+			name:    "rule evaluation error",
+			rules:   []ScaleRule{{Query: "players > 10", Action: 1}},
+			errs:    map[string]error{"players > 10": errQuery},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, provider, mcMetrics, _ := newTestAutoscaler(t, AutoScalerConfig{Rules: tt.rules})
+			if tt.size != "" {
+				provider.size = tt.size
+			}
+			for query, value := range tt.values {
+				mcMetrics.values[query] = value
+			}
+			for query, err := range tt.errs {
+				mcMetrics.errs[query] = err
+			}
+			if tt.scaling {
+				a.scaleLock.Lock()
+				defer a.scaleLock.Unlock()
+			}
+			err := a.CoreLoop(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CoreLoop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := provider.Resizes(); !slices.Equal(got, tt.wantResizes) {
+				t.Errorf("resizes = %v, want %v", got, tt.wantResizes)
+			}
+		})
+	}
+}
+
+// Gauges where lower is worse, like TPS and available memory, use "<" to scale up when they drop.
+func TestLowerIsWorseRule(t *testing.T) {
+	tpsRule := ScaleRule{Name: "low-tps", Query: "min(mc_tps)", Operator: "<", Threshold: 18, Action: 1}
+	memoryRule := ScaleRule{Name: "low-memory", Query: "min(node_memory_MemAvailable_bytes / node_memory_MemTotal_bytes)", Operator: "<", Threshold: 0.1, Action: 1}
+	tests := []struct {
+		name        string
+		rule        ScaleRule
+		value       float64
+		wantResizes []string
+	}{
+		{name: "low tps scales up", rule: tpsRule, value: 12.5, wantResizes: []string{"cax31"}},
+		{name: "tps at threshold does nothing", rule: tpsRule, value: 18},
+		{name: "healthy tps does nothing", rule: tpsRule, value: 20},
+		{name: "low memory scales up", rule: memoryRule, value: 0.05, wantResizes: []string{"cax31"}},
+		{name: "plenty of memory does nothing", rule: memoryRule, value: 0.6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, provider, mcMetrics, _ := newTestAutoscaler(t, AutoScalerConfig{Rules: []ScaleRule{tt.rule}})
+			mcMetrics.values[tt.rule.Query] = vector(tt.value)
+			met, err := a.EvaluateRule(context.Background(), a.Rules[0])
+			if err != nil {
+				t.Fatalf("EvaluateRule() error = %v", err)
+			}
+			if want := tt.wantResizes != nil; met != want {
+				t.Errorf("EvaluateRule() = %v, want %v", met, want)
+			}
+			if err := a.CoreLoop(context.Background()); err != nil {
+				t.Fatalf("CoreLoop() error = %v", err)
+			}
+			if got := provider.Resizes(); !slices.Equal(got, tt.wantResizes) {
+				t.Errorf("resizes = %v, want %v", got, tt.wantResizes)
+			}
+		})
+	}
+}
+
+func TestResultMet(t *testing.T) {
+	nan := model.SampleValue(math.NaN())
+	tests := []struct {
+		name    string
+		result  model.Value
+		want    bool
+		wantErr bool
+	}{
+		{name: "empty vector", result: model.Vector{}, want: false},
+		{name: "non-empty vector", result: vector(3), want: true},
+		{name: "vector of zero", result: vector(0), want: true},
+		{name: "scalar", result: &model.Scalar{Value: 1}, want: true},
+		{name: "zero scalar", result: &model.Scalar{Value: 0}, want: false},
+		{name: "NaN scalar", result: &model.Scalar{Value: nan}, want: false},
+		{name: "empty matrix", result: model.Matrix{}, want: false},
+		{name: "matrix with latest sample", result: model.Matrix{{Values: []model.SamplePair{{Value: nan}, {Value: 4}}}}, want: true},
+		{name: "matrix with NaN latest sample", result: model.Matrix{{Values: []model.SamplePair{{Value: 4}, {Value: nan}}}}, want: false},
+		{name: "matrix with empty series", result: model.Matrix{{}}, want: false},
+		{name: "string", result: &model.String{Value: "up"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resultMet(tt.result)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resultMet() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resultMet() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCoreLoopOnlyQueryErrorsMeanMetricsUnavailable(t *testing.T) {
+	tests := []struct {
+		name             string
+		rule             ScaleRule
+		maintenanceQuery string
+		wantResizes      []string
+	}{
+		{name: "query error", rule: ScaleRule{Query: "players > 10", Action: 1}, wantResizes: []string{"cax41"}},
+		{name: "invalid rule", rule: ScaleRule{RconMetric: "entities", Operator: ">", Threshold: 5000, Action: 1}},
+		{name: "maintenance query error", rule: ScaleRule{Query: "players < 1", Action: -1}, maintenanceQuery: "players > 10", wantResizes: []string{"cax41"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, provider, mcMetrics, _ := newTestAutoscaler(t, AutoScalerConfig{
+				Rules:                []ScaleRule{tt.rule},
+				MaintenanceQuery:     tt.maintenanceQuery,
+				OnMetricsUnavailable: MetricsUnavailableScaleToSafe,
+				SafeSize:             "cax41",
+			})
+			mcMetrics.errs["players > 10"] = errors.New("prometheus is down")
+			if err := a.CoreLoop(context.Background()); err == nil {
+				t.Fatal("CoreLoop() succeeded, want an error")
+			}
+			if got := provider.Resizes(); !slices.Equal(got, tt.wantResizes) {
+				t.Errorf("resizes = %v, want %v", got, tt.wantResizes)
+			}
+		})
+	}
+}
@@ -2,35 +2,158 @@ package autoscaler
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"regexp"
 	"slices"
+	"strings"
 	"sync"
 	"time"
 
-	"github.com/Tnze/go-mc/net"
+	"github.com/markspolakovs/mcas/mcstatus"
 	"github.com/markspolakovs/mcas/metrics"
-	"github.com/markspolakovs/mcas/providers/hcloud"
+	"github.com/markspolakovs/mcas/notify"
 	"github.com/robfig/cron/v3"
 )
 
 type AutoScalerConfig struct {
-	Logger  *slog.Logger
-	Metrics *metrics.PrometheusMCMetrics
-	Scaler  *hcloud.HCloudAutoscaler
+	Logger      *slog.Logger
+	Metrics     metrics.Source
+	SelfMetrics *metrics.SelfMetrics
+	Scaler      Provider
+	// Where scaling decisions get the current time from (default: the system clock).
+	Clock Clock
+
+	// Name of the managed server, available to rule queries as {{.Server}}.
+	ServerName string
 
 	AllowedSizes []string
+	// What to do when the current size isn't in AllowedSizes; one of the UnknownSize* constants.
+	OnUnknownCurrentSize string
+	// How many players each size can hold, for FitToPlayers rules.
+	SizeCapacity map[string]int
+	// What to do when rules can't be evaluated; one of the MetricsUnavailable* constants.
+	OnMetricsUnavailable    string
+	MetricsUnavailableAfter time.Duration
+	SafeSize                string
+	// If set, once the provider has been unreachable for this long, stop scaling and
+	// alert (rather than failing every loop) until it's reachable again.
+	ProviderUnavailableAfter time.Duration
+	// If set, rules and schedules never scale beyond these sizes, even if AllowedSizes has larger/smaller ones.
+	MinSize string
+	MaxSize string
 
+	// How to talk to the server. Defaults to RCON using RconAddress and RconPassword.
+	Controller   MinecraftController
 	RconAddress  string
 	RconPassword string
+	// Console command used to stop the server before resizing, instead of the controller's default (stop).
+	StopCommand string
+	// Don't stop the server from inside, and rely on the provider's StopServer to shut it down.
+	SkipStopCommand bool
+	// If set, refuse to scale up to a size whose hourly price would cost more than this per month.
+	MonthlyBudget float64
+	// If set, reloads that change anything are also sent to the Notifier.
+	NotifyOnReload bool
+	// If set, a rule that is met but can't act because the server is already at its
+	// min or max size is sent to the Notifier, at most once per this interval per rule.
+	NotifyClampedEvery time.Duration
+	// If set, answers server list pings while the server is down for resizing.
+	StatusResponder *mcstatus.Responder
+	// If this Prometheus query returns any results (or a non-zero scalar), rules and schedules don't scale,
+	// e.g. to suspend autoscaling from existing monitoring during maintenance.
+	MaintenanceQuery string
+	// If set, the number of online players is taken from this Prometheus query rather than asking the server.
+	PlayerCountQuery string
+	// Otherwise, if set, it is taken from a server list ping to this address rather
+	// than asking the controller, which is then only used to message and stop the server.
+	PingAddress string
+	// If set, this console command is run every loop and the last submatch of
+	// RconMetricRegex in its output (or the whole match, if it has no groups) is
+	// recorded as the metric RconMetricName, for rules with a matching rcon_metric
+	// and as mcas_rcon_metric.
+	RconMetricCommand string
+	RconMetricRegex   *regexp.Regexp
+	RconMetricName    string
+	// How to tell that the server is empty before stopping it; one of the EmptyCheck* constants.
+	EmptyCheck string
+	// With EmptyCheckNone, how long to wait after the pre-shutdown message before stopping.
+	PreShutdownDelay time.Duration
+	// How often to check the player count while waiting for the server to empty (default 5s).
+	EmptyWaitPollInterval time.Duration
+	// If set, after a resize, wait up to this long for the server to answer ReadyCommand
+	// (default list) over the controller, as RCON may listen before worlds have loaded.
+	ReadyTimeout time.Duration
+	ReadyCommand string
+	// How many consecutive polls must see no players before the server counts as empty (default 1).
+	EmptyConfirmations int
+	// If set, rules only scale down once the server has been continuously empty for this long.
+	ScaleDownEmptyFor time.Duration
+	// If set, scale-down rules go straight to the cheapest size once the server is idle (empty for ScaleDownEmptyFor).
+	IdleScaleToCheapest bool
+	// If set, rules that scale down by more than one size do so one size at a time,
+	// waiting this long between steps and stopping if the rule is no longer met.
+	ScaleDownStepSettle time.Duration
+
+	// If set, run before waiting for the server to be empty, e.g. to send players to a lobby.
+	// The command is sent to DrainRconAddress (such as a proxy) if set, or the server itself otherwise.
+	DrainCommand      string
+	DrainRconAddress  string
+	DrainRconPassword string
 
 	MinTimeBetweenActions time.Duration
+	// If set, override MinTimeBetweenActions for scaling up and down respectively,
+	// e.g. to react quickly to load while staying conservative about scaling down.
+	MinTimeBetweenScaleUp   time.Duration
+	MinTimeBetweenScaleDown time.Duration
+	// After scaling up, refuse to scale down for this long, to avoid flapping.
+	PostScaleUpHold time.Duration
+	// After a resize, rules are still evaluated but not acted on for this long,
+	// as metrics from a freshly started server are noisy or missing.
+	MetricsWarmup time.Duration
+	// How many scaling actions to keep for RecentEvents.
+	RecentEventsSize int
+
+	// If set, called before each scaling action; the action only goes ahead if it returns true.
+	Confirm func(ctx context.Context, proposal string) bool
+	// If set, held from before the server is stopped until the resize is done,
+	// so that mcas doesn't fight other automation such as backups.
+	Locker Locker
+	// How long to wait for Locker before refusing to scale (default 1m).
+	LockTimeout time.Duration
+	// If set, called when a scaling action is about to warn players and wait for the
+	// server to empty, and when it has finished (with its error, if any), e.g. to pause
+	// matchmaking or other automation in the meantime.
+	OnScalePending  func(ctx context.Context)
+	OnScaleComplete func(ctx context.Context, err error)
+
+	// After this many consecutive failed scaling actions, stop attempting to
+	// scale for CircuitBreakerCooldown. Zero disables the circuit breaker.
+	CircuitBreakerThreshold int
+	CircuitBreakerCooldown  time.Duration
 
 	PreShutdownMessage string
+	// If set, the pre-shutdown message is repeated in game this often while waiting for
+	// the server to empty, so that players who join during the wait see it too.
+	PreShutdownRepeatEvery time.Duration
 
-	Rules    []ScaleRule
-	Schedule []ScaleSchedule
+	// If set, scale events and the pre-shutdown message are also posted here.
+	Notifier notify.Notifier
+
+	// If set, events in this iCalendar feed with a size directive in their summary,
+	// e.g. [mcas:cax31], keep the server at that size from CalendarLead before they
+	// start until they end, re-fetching the feed every CalendarRefresh (default 15m).
+	CalendarURL     string
+	CalendarLead    time.Duration
+	CalendarRefresh time.Duration
+
+	Rules       []ScaleRule
+	Schedule    []ScaleSchedule
+	TimeWindows []TimeWindowRule
+	PreScales   []PreScale
+	Alerts      []AlertAction
 }
 
 // Ensures that an Autoscaler cannot be created except by using NewAutoscaler
@@ -38,121 +161,691 @@ type cfg = AutoScalerConfig
 type Autoscaler struct {
 	cfg
 
-	scaleLock    sync.Mutex
-	cron         *cron.Cron
-	lastScaledAt time.Time
+	scaleLock sync.Mutex
+	cron      *cron.Cron
+	startedAt time.Time
+
+	// Guards the outcome of recent scales, which is read outside scaleLock.
+	lastScaleMux        sync.Mutex
+	lastScaledAt        time.Time
+	lastDirection       int
+	warmupUntil         time.Time
+	consecutiveFailures int
+	circuitOpenUntil    time.Time
+
+	consecutiveMetricFailures int
+	metricsFailingSince       time.Time
+
+	providerMux          sync.Mutex
+	providerFailingSince time.Time
+	providerLastError    string
+	providerHolding      bool
+
+	pinMux     sync.Mutex
+	pinUntil   time.Time
+	pinnedSize string
+
+	eventsMux sync.Mutex
+	events    []ScaleEvent
+
+	emptyMux   sync.Mutex
+	emptySince time.Time
+
+	clampedMux      sync.Mutex
+	clampedNotified map[string]time.Time
+
+	rconMetricMux   sync.Mutex
+	rconMetricValue *float64
+
+	calendarMux       sync.Mutex
+	calendarCache     []CalendarEvent
+	calendarFetchedAt time.Time
+
+	// Which Alertmanager alerts are firing, by fingerprint.
+	alertsMux    sync.Mutex
+	alertsFiring map[string]bool
+
+	// Guards the rules, schedules, time windows and pre-scales, which can be replaced by Reload.
+	rulesMux sync.RWMutex
+}
+
+// Validate checks settings that would otherwise only fail once a scale needs them.
+func (cfg AutoScalerConfig) Validate() error {
+	// Messages starting with { are sent with tellraw, which needs a JSON text component.
+	if len(cfg.PreShutdownMessage) > 0 && cfg.PreShutdownMessage[0] == '{' && !json.Valid([]byte(cfg.PreShutdownMessage)) {
+		return fmt.Errorf("pre-shutdown message starts with { but is not a valid JSON text component for tellraw: %q", cfg.PreShutdownMessage)
+	}
+	return nil
 }
 
 func NewAutoscaler(cfg AutoScalerConfig) *Autoscaler {
+	if cfg.SelfMetrics == nil {
+		cfg.SelfMetrics = metrics.NewSelfMetrics()
+	}
+	if cfg.Controller == nil {
+		cfg.Controller = NewRCONController(cfg.RconAddress, cfg.RconPassword)
+	}
+	if cfg.Clock == nil {
+		cfg.Clock = RealClock{}
+	}
+	if cfg.Metrics != nil {
+		cfg.Metrics = unavailableOnError{cfg.Metrics}
+	}
 	return &Autoscaler{
-		cfg: cfg,
+		cfg:       cfg,
+		startedAt: cfg.Clock.Now(),
+	}
+}
+
+func (a *Autoscaler) updateSelfMetrics() {
+	lastScaledAt, _ := a.lastScale()
+	last := lastScaledAt
+	if last.IsZero() {
+		last = a.startedAt
+	}
+	a.SelfMetrics.SecondsSinceLastScale.Set(a.Clock.Now().Sub(last).Seconds())
+	remaining := max(lastScaledAt.Add(a.cooldown(0)).Sub(a.Clock.Now()), 0)
+	a.SelfMetrics.CooldownRemaining.Set(remaining.Seconds())
+	// The breaker closes by itself once its cooldown is over, not only on the next success.
+	if until, _ := a.circuitOpen(); a.Clock.Now().Before(until) {
+		a.SelfMetrics.CircuitOpen.Set(1)
+	} else {
+		a.SelfMetrics.CircuitOpen.Set(0)
 	}
 }
 
+// circuitOpen returns until when the circuit breaker is open, and the number of
+// consecutive failures that opened it.
+func (a *Autoscaler) circuitOpen() (time.Time, int) {
+	a.lastScaleMux.Lock()
+	defer a.lastScaleMux.Unlock()
+	return a.circuitOpenUntil, a.consecutiveFailures
+}
+
+// lastScale returns when the server was last scaled, and in which direction.
+func (a *Autoscaler) lastScale() (time.Time, int) {
+	a.lastScaleMux.Lock()
+	defer a.lastScaleMux.Unlock()
+	return a.lastScaledAt, a.lastDirection
+}
+
+// inWarmup reports whether the metrics may not yet reflect the last scale, and until when.
+func (a *Autoscaler) inWarmup() (bool, time.Time) {
+	a.lastScaleMux.Lock()
+	defer a.lastScaleMux.Unlock()
+	return a.Clock.Now().Before(a.warmupUntil), a.warmupUntil
+}
+
+// cooldown returns the minimum time since the last scaling action before scaling
+// in the given direction. A direction of 0 (not yet known) gives the shorter of the two.
+func (a *Autoscaler) cooldown(direction int) time.Duration {
+	up, down := a.MinTimeBetweenActions, a.MinTimeBetweenActions
+	if a.MinTimeBetweenScaleUp > 0 {
+		up = a.MinTimeBetweenScaleUp
+	}
+	if a.MinTimeBetweenScaleDown > 0 {
+		down = a.MinTimeBetweenScaleDown
+	}
+	switch {
+	case direction > 0:
+		return up
+	case direction < 0:
+		return down
+	}
+	return min(up, down)
+}
+
+// recordScaleOutcome updates the circuit breaker state. Must be called with scaleLock held.
+func (a *Autoscaler) recordScaleOutcome(ctx context.Context, err error) {
+	a.lastScaleMux.Lock()
+	if err == nil {
+		if a.consecutiveFailures > 0 {
+			a.Logger.Info("scaling succeeded, resetting circuit breaker", slog.Int("previousFailures", a.consecutiveFailures))
+		}
+		a.consecutiveFailures = 0
+		a.circuitOpenUntil = time.Time{}
+		a.lastScaleMux.Unlock()
+		return
+	}
+	a.consecutiveFailures++
+	failures := a.consecutiveFailures
+	if a.CircuitBreakerThreshold <= 0 || failures < a.CircuitBreakerThreshold {
+		a.lastScaleMux.Unlock()
+		return
+	}
+	// Once open, a single failed retry after the cooldown (half-open) re-opens the breaker.
+	a.circuitOpenUntil = a.Clock.Now().Add(a.CircuitBreakerCooldown)
+	a.lastScaleMux.Unlock()
+	a.Logger.Error("too many consecutive scaling failures, suspending scaling",
+		slog.Int("failures", failures),
+		slog.Duration("cooldown", a.CircuitBreakerCooldown),
+		slog.String("lastError", err.Error()))
+	a.notify(ctx, notify.SeverityError, fmt.Sprintf("Scaling suspended for %s after %d consecutive failures. Last error: %s", a.CircuitBreakerCooldown, failures, err))
+}
+
+func (a *Autoscaler) priceChange(ctx context.Context, current, new string) (float64, float64, error) {
+	currentPrice, err := a.Scaler.GetPrice(ctx, current)
+	if err != nil {
+		return 0, 0, err
+	}
+	newPrice, err := a.Scaler.GetPrice(ctx, new)
+	if err != nil {
+		return 0, 0, err
+	}
+	return currentPrice, newPrice, nil
+}
+
+// updatePriceMetric sets the current hourly price gauge from the server's current size.
+func (a *Autoscaler) updatePriceMetric(ctx context.Context) error {
+	current, err := a.Scaler.GetCurrentSize(ctx)
+	if err != nil {
+		return err
+	}
+	price, err := a.Scaler.GetPrice(ctx, current)
+	if err != nil {
+		return err
+	}
+	a.SelfMetrics.CurrentHourlyPrice.Set(price)
+	return nil
+}
+
+// Pin suspends all automatic scaling for d. If size is set, the server is first
+// resized to it in the background.
+func (a *Autoscaler) Pin(ctx context.Context, size string, d time.Duration) (time.Time, error) {
+	if size != "" && !slices.Contains(a.AllowedSizes, size) {
+		return time.Time{}, fmt.Errorf("%s is not an allowed size", size)
+	}
+	a.pinMux.Lock()
+	a.pinUntil = a.Clock.Now().Add(d)
+	a.pinnedSize = size
+	until := a.pinUntil
+	a.pinMux.Unlock()
+	a.Logger.Info("pinned", slog.Time("until", until), slog.String("size", size))
+	if size != "" {
+		go func() {
+			current, sizes, err := a.getCurrentSize(ctx)
+			if err != nil {
+				a.Logger.Error("failed to get current size", slog.String("err", err.Error()))
+				return
+			}
+			if sizes[current] == size {
+				return
+			}
+			err = a.doScale(ctx, scaleRequest{target: size, ignoreCooldown: true, ignorePin: true, trigger: "pin"})
+			if err != nil {
+				a.Logger.Error("failed to scale to pinned size", slog.String("size", size), slog.String("err", err.Error()))
+			}
+		}()
+	}
+	return until, nil
+}
+
+// enforcePin scales to the pinned size, if the pin has one and the server isn't at
+// it, e.g. because the resize when pinning failed or it was resized by hand.
+func (a *Autoscaler) enforcePin(ctx context.Context) error {
+	a.pinMux.Lock()
+	size := a.pinnedSize
+	a.pinMux.Unlock()
+	if size == "" {
+		return nil
+	}
+	current, sizes, err := a.getCurrentSize(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current size: %w", err)
+	}
+	if sizes[current] == size {
+		return nil
+	}
+	a.Logger.Info("pinned, scaling to the pinned size", slog.String("current", sizes[current]), slog.String("target", size))
+	return a.doScale(ctx, scaleRequest{target: size, ignoreCooldown: true, ignorePin: true, trigger: "pin"})
+}
+
+func (a *Autoscaler) Unpin() {
+	a.pinMux.Lock()
+	defer a.pinMux.Unlock()
+	a.pinUntil = time.Time{}
+	a.pinnedSize = ""
+	a.Logger.Info("unpinned")
+}
+
+// pinnedUntil returns when the current pin expires, or the zero time if not pinned.
+func (a *Autoscaler) pinnedUntil() time.Time {
+	a.pinMux.Lock()
+	defer a.pinMux.Unlock()
+	if a.Clock.Now().After(a.pinUntil) {
+		return time.Time{}
+	}
+	return a.pinUntil
+}
+
+func (a *Autoscaler) notify(ctx context.Context, severity notify.Severity, message string) {
+	if a.Notifier == nil {
+		return
+	}
+	if err := a.Notifier.Notify(ctx, severity, message); err != nil {
+		a.Logger.Warn("failed to send notification", slog.String("error", err.Error()))
+	}
+}
+
+func (a *Autoscaler) notifyScale(ctx context.Context, event ScaleEvent) {
+	sn, ok := a.Notifier.(notify.ScaleNotifier)
+	if !ok {
+		return
+	}
+	if err := sn.NotifyScale(ctx, notify.ScaleEvent(event)); err != nil {
+		a.Logger.Warn("failed to send scale event", slog.String("error", err.Error()))
+	}
+}
+
+// plainText renders a tellraw JSON text component as plain text. Anything else is returned as-is.
+func plainText(message string) string {
+	if len(message) == 0 || message[0] != '{' {
+		return message
+	}
+	var component any
+	if err := json.Unmarshal([]byte(message), &component); err != nil {
+		return message
+	}
+	var sb strings.Builder
+	var walk func(any)
+	walk = func(c any) {
+		switch c := c.(type) {
+		case string:
+			sb.WriteString(c)
+		case []any:
+			for _, child := range c {
+				walk(child)
+			}
+		case map[string]any:
+			if text, ok := c["text"].(string); ok {
+				sb.WriteString(text)
+			}
+			walk(c["extra"])
+		}
+	}
+	walk(component)
+	return sb.String()
+}
+
+func directionLabel(direction int) string {
+	if direction < 0 {
+		return "down"
+	}
+	return "up"
+}
+
+var ErrNoAllowedSizes = errors.New("no allowed sizes")
+
+// Policies for when the server's current size is not one of the allowed sizes,
+// e.g. because it was resized outside of mcas.
+const (
+	// Return an error, preventing any scaling.
+	UnknownSizeError = "error"
+	// Treat the current size as part of the ladder, and resize to the nearest allowed size.
+	UnknownSizeScaleToNearest = "scale-to-nearest-allowed"
+	// Treat the current size as part of the ladder.
+	UnknownSizeAdopt = "adopt"
+)
+
 func (a *Autoscaler) getCurrentSize(ctx context.Context) (int, []string, error) {
-	sizes, err := a.Scaler.GetAvailableSizes(ctx)
+	available, err := a.Scaler.GetAvailableSizes(ctx)
 	if err != nil {
 		return 0, nil, fmt.Errorf("failed to get scale sizes: %w", err)
 	}
-	slog.Debug("available sizes", slog.Any("sizes", sizes))
-	sizes = slices.DeleteFunc(sizes, func(s string) bool {
+	slog.Debug("available sizes", slog.Any("sizes", available))
+	sizes := slices.DeleteFunc(slices.Clone(available), func(s string) bool {
 		return !slices.Contains(a.AllowedSizes, s)
 	})
 	slog.Debug("allowed sizes", slog.Any("sizes", sizes))
+	if len(sizes) == 0 {
+		arch, location := a.Scaler.Placement()
+		return 0, nil, fmt.Errorf("%w: none of %v match this server's architecture (%s) and location (%s)", ErrNoAllowedSizes, a.AllowedSizes, arch, location)
+	}
 	current, err := a.Scaler.GetCurrentSize(ctx)
 	if err != nil {
 		return 0, nil, fmt.Errorf("failed to get current size: %w", err)
 	}
 	currentIndex := slices.Index(sizes, current)
 	if currentIndex == -1 {
-		return 0, nil, fmt.Errorf("current size (%s) not found in sizes", current)
+		if a.OnUnknownCurrentSize != UnknownSizeAdopt && a.OnUnknownCurrentSize != UnknownSizeScaleToNearest {
+			return 0, nil, fmt.Errorf("current size (%s) not found in sizes", current)
+		}
+		// Rebuild the ladder from the (price-ordered) available sizes, so the current size lands in the right place.
+		sizes = slices.DeleteFunc(available, func(s string) bool {
+			return s != current && !slices.Contains(a.AllowedSizes, s)
+		})
+		currentIndex = slices.Index(sizes, current)
+		if currentIndex == -1 {
+			return 0, nil, fmt.Errorf("current size (%s) not found in available sizes", current)
+		}
+		a.Logger.Warn("current size is not an allowed size, adopting it", slog.String("current", current), slog.Any("sizes", sizes))
 	}
 	return currentIndex, sizes, nil
 }
 
-func (a *Autoscaler) getNewSize(current, action int, sizes []string) (int, string) {
+// reconcileUnknownSize resizes the server to the nearest allowed size if it is
+// currently at a size outside the ladder, preferring the next larger size.
+func (a *Autoscaler) reconcileUnknownSize(ctx context.Context) error {
+	if a.OnUnknownCurrentSize != UnknownSizeScaleToNearest {
+		return nil
+	}
+	current, sizes, err := a.getCurrentSize(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get current size: %w", err)
+	}
+	if slices.Contains(a.AllowedSizes, sizes[current]) {
+		return nil
+	}
+	if len(sizes) == 1 {
+		return fmt.Errorf("current size (%s) is not allowed and there is no allowed size to scale to", sizes[current])
+	}
+	target := current + 1
+	if target >= len(sizes) {
+		target = current - 1
+	}
+	a.Logger.Warn("current size is not an allowed size, scaling to the nearest allowed size", slog.String("current", sizes[current]), slog.String("target", sizes[target]))
+	return a.doScale(ctx, scaleRequest{target: sizes[target], trigger: "unknown size"})
+}
+
+func (a *Autoscaler) getNewSize(current, action int, sizes []string) (int, string, error) {
+	if len(sizes) == 0 {
+		return 0, "", ErrNoAllowedSizes
+	}
+	minIndex, maxIndex := a.sizeBounds(sizes)
 	newIndex := current + action
-	if newIndex < 0 {
-		newIndex = 0
+	if newIndex < minIndex {
+		newIndex = minIndex
+	}
+	if newIndex > maxIndex {
+		newIndex = maxIndex
+	}
+	// If the server is already outside the bounds, don't let clamping move it the wrong way.
+	if (action > 0 && newIndex < current) || (action < 0 && newIndex > current) {
+		newIndex = current
+	}
+	return newIndex, sizes[newIndex], nil
+}
+
+// CheckSizeBounds checks that MinSize and MaxSize are sizes the server can be
+// resized to, and that MinSize is no larger than MaxSize.
+func (a *Autoscaler) CheckSizeBounds(ctx context.Context) error {
+	if a.MinSize == "" && a.MaxSize == "" {
+		return nil
+	}
+	available, err := a.Scaler.GetAvailableSizes(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get scale sizes: %w", err)
+	}
+	sizes := slices.DeleteFunc(available, func(s string) bool {
+		return !slices.Contains(a.AllowedSizes, s)
+	})
+	for _, size := range []string{a.MinSize, a.MaxSize} {
+		if size != "" && !slices.Contains(sizes, size) {
+			return fmt.Errorf("size %s is not an allowed size that this server can be resized to (%v)", size, sizes)
+		}
+	}
+	if minIndex, maxIndex := a.sizeBounds(sizes); minIndex > maxIndex {
+		return fmt.Errorf("min size %s is larger than max size %s", a.MinSize, a.MaxSize)
+	}
+	return nil
+}
+
+// sizeBounds returns the indexes in sizes of MinSize and MaxSize, or the ends of the ladder if they are unset.
+func (a *Autoscaler) sizeBounds(sizes []string) (int, int) {
+	minIndex, maxIndex := 0, len(sizes)-1
+	if i := slices.Index(sizes, a.MinSize); a.MinSize != "" && i != -1 {
+		minIndex = i
 	}
-	if newIndex >= len(sizes) {
-		newIndex = len(sizes) - 1
+	if i := slices.Index(sizes, a.MaxSize); a.MaxSize != "" && i != -1 {
+		maxIndex = i
 	}
-	return newIndex, sizes[newIndex]
+	return minIndex, maxIndex
 }
 
-func (a *Autoscaler) CanScale(ctx context.Context, direction int) (bool, error) {
+// inPostScaleUpHold reports whether a scale in the given direction is refused
+// because of a recent scale-up, and until when.
+func (a *Autoscaler) inPostScaleUpHold(direction int) (bool, time.Time) {
+	lastScaledAt, lastDirection := a.lastScale()
+	if direction >= 0 || lastDirection <= 0 {
+		return false, time.Time{}
+	}
+	until := lastScaledAt.Add(a.PostScaleUpHold)
+	return a.Clock.Now().Before(until), until
+}
+
+// Reason explains why CanScale did or didn't allow a scale.
+type Reason string
+
+const (
+	ReasonOK              Reason = "ok"
+	ReasonAtMin           Reason = "at_min_size"
+	ReasonAtMax           Reason = "at_max_size"
+	ReasonNoEligibleSize  Reason = "no_eligible_size"
+	ReasonPostScaleUpHold Reason = "post_scale_up_hold"
+	ReasonNotIdle         Reason = "not_idle"
+)
+
+func (a *Autoscaler) CanScale(ctx context.Context, direction int) (bool, Reason, error) {
+	if held, until := a.inPostScaleUpHold(direction); held {
+		a.Logger.Info("cannot scale down so soon after scaling up", slog.Time("until", until))
+		return false, ReasonPostScaleUpHold, nil
+	}
+	if a.inIdleHold(direction) {
+		a.Logger.Info("cannot scale down until the server has been empty for long enough", slog.Duration("emptyFor", a.ScaleDownEmptyFor))
+		return false, ReasonNotIdle, nil
+	}
 	currentIndex, sizes, err := a.getCurrentSize(ctx)
 	if err != nil {
-		return false, fmt.Errorf("failed to get current size: %w", err)
+		return false, "", fmt.Errorf("failed to get current size: %w", err)
+	}
+	newIndex, newSize, err := a.getNewSize(currentIndex, direction, sizes)
+	if err != nil {
+		return false, "", err
 	}
-	newIndex, newSize := a.getNewSize(currentIndex, direction, sizes)
 	ok := newIndex != currentIndex
 	slog.Debug("can scale", slog.Bool("ok", ok), slog.Int("direction", direction), slog.String("currentSize", sizes[currentIndex]), slog.Int("currentIndex", currentIndex), slog.Any("sizes", sizes))
-	if !ok {
+	minIndex, maxIndex := a.sizeBounds(sizes)
+	switch {
+	case ok:
+		return true, ReasonOK, nil
+	case direction > 0 && currentIndex >= maxIndex:
+		a.Logger.Info("cannot scale up because the server is at max size", slog.String("current", sizes[currentIndex]), slog.String("maxSize", sizes[maxIndex]))
+		return false, ReasonAtMax, nil
+	case direction < 0 && currentIndex <= minIndex:
+		a.Logger.Info("cannot scale down because the server is at min size", slog.String("current", sizes[currentIndex]), slog.String("minSize", sizes[minIndex]))
+		return false, ReasonAtMin, nil
+	default:
 		a.Logger.Info("cannot scale because there is no eligible size", slog.String("current", sizes[currentIndex]), slog.String("new", newSize), slog.Int("direction", direction), slog.Any("sizes", sizes))
+		return false, ReasonNoEligibleSize, nil
 	}
-	return ok, nil
 }
 
-func (a *Autoscaler) prepareForScalingAction(ctx context.Context) error {
-	rcon, err := net.DialRCON(a.RconAddress, a.RconPassword)
-	if err != nil {
-		return fmt.Errorf("failed to dial RCON: %w", err)
+func (a *Autoscaler) prepareForScalingAction(ctx context.Context, direction int, newSize string, skipEmptyWait bool) error {
+	defer a.Controller.Close()
+	// Let the provider get ready for the resize while players are warned and leave.
+	var resizePrepared chan error
+	if preparer, ok := a.Scaler.(ResizePreparer); ok {
+		prepareCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		resizePrepared = make(chan error, 1)
+		go func() { resizePrepared <- preparer.PrepareResize(prepareCtx, newSize) }()
 	}
-	defer rcon.Close()
 	a.Logger.Debug("sending pre-shutdown message", slog.String("message", a.PreShutdownMessage))
-	if a.PreShutdownMessage[0] == '{' {
-		err = rcon.Cmd(`tellraw @a ` + a.PreShutdownMessage)
-	} else {
-		err = rcon.Cmd(`say ` + a.PreShutdownMessage)
-	}
+	// The webhook is best-effort and mustn't hold up the in-game message.
+	go a.notify(ctx, notify.SeverityInfo, plainText(a.PreShutdownMessage))
+	err := a.Controller.Broadcast(ctx, a.PreShutdownMessage)
 	if err != nil {
-		return fmt.Errorf("failed to send tellraw command: %w", err)
+		return fmt.Errorf("failed to send pre-shutdown message: %w", err)
 	}
-	_, err = rcon.Resp()
-	if err != nil {
-		return fmt.Errorf("failed to read response from server: %w", err)
+
+	if a.DrainCommand != "" {
+		err = a.drainPlayers(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to drain players: %w", err)
+		}
 	}
 
-	err = waitForServerToBeEmpty(ctx, rcon, 5*time.Minute)
-	if err != nil {
-		return fmt.Errorf("failed to wait for server to be empty: %w", err)
+	waitCtx, stopRepeating := context.WithCancel(ctx)
+	defer stopRepeating()
+	if !skipEmptyWait && a.PreShutdownRepeatEvery > 0 {
+		go a.repeatPreShutdownMessage(waitCtx)
+	}
+	switch {
+	case skipEmptyWait:
+		a.Logger.Warn("not waiting for server to be empty")
+	case a.EmptyCheck == EmptyCheckNone:
+		a.Logger.Info("not checking whether the server is empty, waiting before stopping", slog.Duration("delay", a.PreShutdownDelay))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(a.PreShutdownDelay):
+		}
+	default:
+		var abort func(context.Context) (bool, error)
+		if direction < 0 {
+			abort = a.scaleUpNeeded
+		}
+		pollInterval := a.EmptyWaitPollInterval
+		if pollInterval <= 0 {
+			pollInterval = 5 * time.Second
+		}
+		err = waitForServerToBeEmpty(ctx, a.playerCount, 5*time.Minute, pollInterval, max(a.EmptyConfirmations, 1), abort)
+		if err != nil {
+			return fmt.Errorf("failed to wait for server to be empty: %w", err)
+		}
+	}
+	stopRepeating()
+	if resizePrepared != nil {
+		if err := <-resizePrepared; err != nil {
+			return fmt.Errorf("failed to prepare provider for resize: %w", err)
+		}
 	}
 
-	err = rcon.Cmd(`stop`)
+	switch {
+	case a.SkipStopCommand:
+		a.Logger.Info("not sending a stop command, leaving shutdown to the provider")
+	case a.StopCommand != "":
+		_, err = a.Controller.Command(ctx, a.StopCommand)
+	default:
+		err = a.Controller.Stop(ctx)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to stop server: %w", err)
 	}
-	_, err = rcon.Resp()
+	return nil
+}
+
+// repeatPreShutdownMessage broadcasts the pre-shutdown message every PreShutdownRepeatEvery until ctx is done.
+func (a *Autoscaler) repeatPreShutdownMessage(ctx context.Context) {
+	ticker := time.NewTicker(a.PreShutdownRepeatEvery)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		a.Logger.Debug("repeating pre-shutdown message")
+		if err := a.Controller.Broadcast(ctx, a.PreShutdownMessage); err != nil {
+			a.Logger.Warn("failed to repeat pre-shutdown message", slog.String("error", err.Error()))
+		}
+	}
+}
+
+func (a *Autoscaler) drainPlayers(ctx context.Context) error {
+	var target MinecraftController = a.Controller
+	if a.DrainRconAddress != "" {
+		proxy := NewRCONController(a.DrainRconAddress, a.DrainRconPassword)
+		defer proxy.Close()
+		target = proxy
+	}
+	a.Logger.Info("draining players", slog.String("command", a.DrainCommand))
+	resp, err := target.Command(ctx, a.DrainCommand)
 	if err != nil {
-		return fmt.Errorf("failed to read response from server: %w", err)
+		return fmt.Errorf("failed to send drain command: %w", err)
 	}
+	a.Logger.Debug("drain response", slog.String("response", resp))
 	return nil
 }
 
-var listRe = regexp.MustCompile(`There are (\d+) out of maximum \d+ players online\..*`)
-var formatRe = regexp.MustCompile(`§[0-9a-z]`)
+const (
+	// Wait until the player count is zero.
+	EmptyCheckPlayers = "players"
+	// Don't check; wait PreShutdownDelay instead. For servers where the player count can't be read.
+	EmptyCheckNone = "none"
+)
+
+// abortCheckInterval is how often waitForServerToBeEmpty re-checks whether it should abort.
+const abortCheckInterval = 30 * time.Second
 
-func waitForServerToBeEmpty(ctx context.Context, rcon net.RCONClientConn, timeout time.Duration) error {
+var ErrScaleAborted = errors.New("scaling aborted")
+
+// scaleUpNeeded reports whether any scale-up rule is currently met. It is used to
+// abandon a scale-down if the server gets busy again while waiting for it to empty.
+func (a *Autoscaler) scaleUpNeeded(ctx context.Context) (bool, error) {
+	for _, rule := range a.CurrentRules().Rules {
+		if rule.Action <= 0 || !rule.IsEnabled() {
+			continue
+		}
+		met, err := a.EvaluateRule(ctx, rule)
+		if err != nil {
+			return false, err
+		}
+		if met {
+			a.Logger.Info("scale-up rule met while waiting to scale down", slog.String("rule", rule.String()))
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// playerCountFromMetrics returns the player count reported by PlayerCountQuery.
+func (a *Autoscaler) playerCountFromMetrics(ctx context.Context) (int, error) {
+	value, err := a.Metrics.QueryScalar(ctx, a.PlayerCountQuery)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query player count: %w", err)
+	}
+	return int(value), nil
+}
+
+// ErrServerHealthUnknown is returned (wrapped) when the player count can't be
+// determined while waiting for the server to empty, for example because it has
+// crashed. An unreachable server blocks scaling by design: we can't tell that it's
+// empty, so the scale is aborted rather than risk resizing under players.
+var ErrServerHealthUnknown = fmt.Errorf("%w: server health unknown", ErrScaleAborted)
+
+func waitForServerToBeEmpty(ctx context.Context, playerCount func(context.Context) (int, error), timeout, pollInterval time.Duration, confirmations int, abort func(context.Context) (bool, error)) error {
 	deadline := time.After(timeout)
+	var lastAbortCheck time.Time
+	empty := 0
 	for {
-		err := rcon.Cmd(`list`)
-		if err != nil {
-			return fmt.Errorf("failed to send list command: %w", err)
+		if abort != nil && time.Since(lastAbortCheck) >= abortCheckInterval {
+			lastAbortCheck = time.Now()
+			shouldAbort, err := abort(ctx)
+			if err != nil {
+				slog.Warn("failed to check whether to abort scaling", slog.String("error", err.Error()))
+			} else if shouldAbort {
+				return ErrScaleAborted
+			}
+		}
+		count, err := playerCount(ctx)
+		if err == nil && count < 0 {
+			err = fmt.Errorf("invalid player count %d", count)
 		}
-		resp, err := rcon.Resp()
 		if err != nil {
-			return fmt.Errorf("failed to read response: %w", err)
+			return fmt.Errorf("%w: %w", ErrServerHealthUnknown, err)
 		}
-		slog.Debug("list response", slog.String("response", resp))
-		resp = formatRe.ReplaceAllString(resp, "")
-		match := listRe.FindStringSubmatch(resp)
-		if match == nil {
-			return fmt.Errorf("list response does not match expected format: %q", resp)
+		slog.Info("online players", slog.Int("count", count))
+		// A single empty reading may be a player briefly dropping, so wait for several in a row.
+		if count == 0 {
+			empty++
+		} else {
+			empty = 0
 		}
-		slog.Info("online players", slog.String("count", match[1]))
-		if match[1] == "0" {
+		if empty >= confirmations {
 			return nil
 		}
 		select {
@@ -160,44 +853,248 @@ func waitForServerToBeEmpty(ctx context.Context, rcon net.RCONClientConn, timeou
 			return ctx.Err()
 		case <-deadline:
 			return fmt.Errorf("server not empty after %s", timeout)
-		case <-time.After(5 * time.Second):
+		case <-time.After(pollInterval):
 		}
 	}
 }
 
+// ErrScaleRefused is returned (wrapped) when a scaling action is deliberately
+// not carried out, as opposed to failing.
+var ErrScaleRefused = errors.New("scaling refused")
+
+var ErrBudgetExceeded = fmt.Errorf("%w: monthly budget exceeded", ErrScaleRefused)
+
+// HoursPerMonth is used to project hourly prices to a monthly cost.
+const HoursPerMonth = 730
+
+// scaleRequest describes a scaling action: either a number of steps in the
+// ladder, or a specific target size.
+type scaleRequest struct {
+	direction int
+	target    string
+
+	ignoreCooldown bool
+	skipEmptyWait  bool
+	ignorePin      bool
+	// Refuse a scale-down until the server has been empty for ScaleDownEmptyFor, for
+	// rules that pick a target size (CanScale checks this for the others).
+	idleHold bool
+	// Also grow the disk, which can't be undone.
+	upgradeDisk bool
+	// What caused the scale, for RecentEvents.
+	trigger string
+}
+
 func (a *Autoscaler) DoScale(ctx context.Context, direction int) error {
+	return a.doScale(ctx, scaleRequest{direction: direction, trigger: "manual"})
+}
+
+// ScaleTo resizes the server to the given allowed size.
+func (a *Autoscaler) ScaleTo(ctx context.Context, size string) error {
+	return a.doScale(ctx, scaleRequest{target: size, trigger: "manual"})
+}
+
+func (a *Autoscaler) doScale(ctx context.Context, req scaleRequest) (err error) {
 	if !a.scaleLock.TryLock() {
 		return fmt.Errorf("scaling already in progress")
 	}
 	defer a.scaleLock.Unlock()
-	if a.lastScaledAt.Add(a.MinTimeBetweenActions).After(time.Now()) {
-		return fmt.Errorf("scaling too soon")
+	lastScaledAt, _ := a.lastScale()
+	if lastScaledAt.Add(a.cooldown(req.direction)).After(a.Clock.Now()) {
+		if !req.ignoreCooldown {
+			return fmt.Errorf("scaling too soon")
+		}
+		a.Logger.Warn("bypassing cooldown", slog.String("trigger", req.trigger), slog.Time("lastScaledAt", lastScaledAt), slog.Duration("minTimeBetweenActions", a.cooldown(req.direction)))
 	}
+	if until := a.pinnedUntil(); !req.ignorePin && !until.IsZero() {
+		a.Logger.Info("pinned, not scaling", slog.Time("until", until))
+		return fmt.Errorf("%w: pinned until %s", ErrScaleRefused, until.Format(time.RFC3339))
+	}
+	if until, failures := a.circuitOpen(); a.Clock.Now().Before(until) {
+		return fmt.Errorf("circuit breaker open until %s after %d consecutive failures", until.Format(time.RFC3339), failures)
+	}
+	if err := a.Scaler.Refresh(ctx); err != nil {
+		return fmt.Errorf("failed to refresh server state: %w", err)
+	}
+	direction := req.direction
+	var from, to string
+	alreadyAtTarget := false
+	defer func() {
+		if alreadyAtTarget {
+			return
+		}
+		outcome := "success"
+		switch {
+		case errors.Is(err, ErrScaleAborted):
+			outcome = "aborted"
+		case errors.Is(err, ErrScaleRefused):
+			outcome = "refused"
+		case err != nil:
+			outcome = "error"
+			a.recordScaleOutcome(ctx, err)
+		default:
+			a.recordScaleOutcome(ctx, nil)
+		}
+		a.SelfMetrics.ScaleActions.WithLabelValues(directionLabel(direction), outcome).Inc()
+		a.updateSelfMetrics()
+		event := ScaleEvent{Time: a.Clock.Now(), From: from, To: to, Trigger: req.trigger, Outcome: outcome}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		a.recordEvent(event)
+		switch outcome {
+		case "success":
+			a.notify(ctx, notify.SeverityInfo, fmt.Sprintf("Server resized from %s to %s.", from, to))
+		case "error":
+			a.notify(ctx, notify.SeverityError, fmt.Sprintf("Failed to resize server from %s to %s: %s", from, to, err))
+		}
+		a.notifyScale(ctx, event)
+	}()
 	currentIndex, sizess, err := a.getCurrentSize(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to get current size: %w", err)
 	}
 
-	_, newSize := a.getNewSize(currentIndex, direction, sizess)
-	slog.Info("scaling", slog.String("current", sizess[currentIndex]), slog.String("new", newSize))
-	err = a.prepareForScalingAction(ctx)
+	var newSize string
+	if req.target != "" {
+		newIndex := slices.Index(sizess, req.target)
+		if newIndex == -1 {
+			return fmt.Errorf("target size %s is not an allowed size", req.target)
+		}
+		newSize = req.target
+		direction = newIndex - currentIndex
+	} else {
+		_, newSize, err = a.getNewSize(currentIndex, direction, sizess)
+		if err != nil {
+			return err
+		}
+	}
+	from, to = sizess[currentIndex], newSize
+	if from == to {
+		a.Logger.Info("already at target size, not scaling", slog.String("size", to))
+		alreadyAtTarget = true
+		return nil
+	}
+	// For a target size the direction, and so the cooldown, is only known now.
+	if req.target != "" && !req.ignoreCooldown && lastScaledAt.Add(a.cooldown(direction)).After(a.Clock.Now()) {
+		return fmt.Errorf("%w: scaling %s too soon", ErrScaleRefused, directionLabel(direction))
+	}
+	if held, until := a.inPostScaleUpHold(direction); held {
+		return fmt.Errorf("%w: scale-down held after recent scale-up until %s", ErrScaleRefused, until.Format(time.RFC3339))
+	}
+	if req.idleHold && a.inIdleHold(direction) {
+		return fmt.Errorf("%w: server has not been empty for %s", ErrScaleRefused, a.ScaleDownEmptyFor)
+	}
+	action := fmt.Sprintf("scale from %s to %s", sizess[currentIndex], newSize)
+	if req.upgradeDisk {
+		if _, ok := a.Scaler.(DiskUpgrader); !ok {
+			return fmt.Errorf("%w: provider can't upgrade the server's disk", ErrScaleRefused)
+		}
+		action += " and upgrade the disk, which can't be undone"
+	}
+	if a.Confirm != nil && !a.Confirm(ctx, action) {
+		return fmt.Errorf("%w: not confirmed", ErrScaleRefused)
+	}
+	currentPrice, newPrice, priceErr := a.priceChange(ctx, sizess[currentIndex], newSize)
+	if priceErr != nil {
+		a.Logger.Warn("failed to get prices", slog.String("error", priceErr.Error()))
+		slog.Info("scaling", slog.String("current", sizess[currentIndex]), slog.String("new", newSize))
+	} else {
+		slog.Info("scaling",
+			slog.String("current", sizess[currentIndex]), slog.Float64("currentHourlyPrice", currentPrice),
+			slog.String("new", newSize), slog.Float64("newHourlyPrice", newPrice),
+			slog.Float64("hourlyPriceDelta", newPrice-currentPrice))
+	}
+	if a.MonthlyBudget > 0 && direction > 0 {
+		if priceErr != nil {
+			a.Logger.Warn("can't check scale-up against monthly budget without prices")
+		} else if projected := newPrice * HoursPerMonth; projected > a.MonthlyBudget {
+			a.notify(ctx, notify.SeverityWarning, fmt.Sprintf("Not resizing server from %s to %s: projected monthly cost %.2f is over the budget of %.2f.", from, to, projected, a.MonthlyBudget))
+			return fmt.Errorf("%w: %s would cost %.2f a month, budget is %.2f", ErrBudgetExceeded, newSize, projected, a.MonthlyBudget)
+		}
+	}
+	if a.Locker != nil {
+		timeout := a.LockTimeout
+		if timeout <= 0 {
+			timeout = time.Minute
+		}
+		lockCtx, cancel := context.WithTimeout(ctx, timeout)
+		err := a.Locker.Lock(lockCtx)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("%w: failed to acquire lock: %w", ErrScaleRefused, err)
+		}
+		defer func() {
+			if err := a.Locker.Unlock(); err != nil {
+				a.Logger.Error("failed to release lock", slog.String("error", err.Error()))
+			}
+		}()
+	}
+	if a.OnScalePending != nil {
+		a.OnScalePending(ctx)
+	}
+	if a.OnScaleComplete != nil {
+		defer func() { a.OnScaleComplete(ctx, err) }()
+	}
+	err = a.prepareForScalingAction(ctx, direction, newSize, req.skipEmptyWait)
 	if err != nil {
 		return fmt.Errorf("failed to prepare for scaling action: %w", err)
 	}
 
+	// From here on the server is going down, so see the resize through even if ctx is
+	// cancelled (e.g. by --loop-timeout or a signal) rather than leave it stopped.
+	ctx = context.WithoutCancel(ctx)
 	slog.Info("stopping server")
 	err = a.Scaler.StopServer(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to stop server: %w", err)
 	}
 
+	if a.StatusResponder != nil {
+		if err := a.StatusResponder.Start(); err != nil {
+			a.Logger.Warn("failed to start status responder", slog.String("error", err.Error()))
+		} else {
+			// Stop it even if the resize fails, so it isn't left holding the server's port.
+			defer func() {
+				if err := a.StatusResponder.Stop(); err != nil {
+					a.Logger.Warn("failed to stop status responder", slog.String("error", err.Error()))
+				}
+			}()
+		}
+	}
+
 	slog.Info("server stopped, resizing")
-	err = a.Scaler.ResizeServer(ctx, newSize)
+	if req.upgradeDisk {
+		a.Logger.Warn("upgrading disk, the server won't be able to scale back to sizes with less disk", slog.String("new", newSize))
+		err = a.Scaler.(DiskUpgrader).ResizeServerUpgradingDisk(ctx, newSize)
+	} else {
+		err = a.Scaler.ResizeServer(ctx, newSize)
+	}
 	if err != nil {
 		return fmt.Errorf("failed to resize server: %w", err)
 	}
+	// Free the port before the server comes back up, rather than when the scale finishes.
+	if a.StatusResponder != nil {
+		if err := a.StatusResponder.Stop(); err != nil {
+			a.Logger.Warn("failed to stop status responder", slog.String("error", err.Error()))
+		}
+	}
 
 	slog.Info("server resized")
-	a.lastScaledAt = time.Now()
+	if err := a.Scaler.Refresh(ctx); err != nil {
+		a.Logger.Warn("failed to refresh server state after resize", slog.String("error", err.Error()))
+	}
+	if err := a.waitForReady(ctx); err != nil {
+		a.Logger.Warn("server did not become ready after resize", slog.String("error", err.Error()))
+		a.notify(ctx, notify.SeverityWarning, fmt.Sprintf("Server resized to %s but is not responding: %s", newSize, err))
+	}
+	a.lastScaleMux.Lock()
+	a.lastScaledAt = a.Clock.Now()
+	a.lastDirection = direction
+	a.warmupUntil = a.lastScaledAt.Add(a.MetricsWarmup)
+	a.lastScaleMux.Unlock()
+	if priceErr == nil {
+		a.SelfMetrics.CurrentHourlyPrice.Set(newPrice)
+	}
 	return nil
 }
@@ -0,0 +1,234 @@
+package autoscaler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestGetNewSize(t *testing.T) {
+	ladder := []string{"cax11", "cax21", "cax31", "cax41"}
+	tests := []struct {
+		name             string
+		sizes            []string
+		minSize, maxSize string
+		current, action  int
+		wantIndex        int
+		wantSize         string
+		wantErr          error
+	}{
+		{name: "mid-range up", sizes: ladder, current: 1, action: 1, wantIndex: 2, wantSize: "cax31"},
+		{name: "mid-range down", sizes: ladder, current: 2, action: -1, wantIndex: 1, wantSize: "cax21"},
+		{name: "below 0", sizes: ladder, current: 1, action: -5, wantIndex: 0, wantSize: "cax11"},
+		{name: "past the end", sizes: ladder, current: 2, action: 5, wantIndex: 3, wantSize: "cax41"},
+		{name: "single element up", sizes: []string{"cax11"}, current: 0, action: 1, wantIndex: 0, wantSize: "cax11"},
+		{name: "single element down", sizes: []string{"cax11"}, current: 0, action: -1, wantIndex: 0, wantSize: "cax11"},
+		{name: "empty", sizes: nil, current: 0, action: 1, wantErr: ErrNoAllowedSizes},
+		{name: "clamped to max size", sizes: ladder, maxSize: "cax31", current: 1, action: 2, wantIndex: 2, wantSize: "cax31"},
+		{name: "clamped to min size", sizes: ladder, minSize: "cax21", current: 2, action: -2, wantIndex: 1, wantSize: "cax21"},
+		{name: "above max size doesn't move up", sizes: ladder, maxSize: "cax21", current: 3, action: 1, wantIndex: 3, wantSize: "cax41"},
+		{name: "below min size doesn't move down", sizes: ladder, minSize: "cax31", current: 0, action: -1, wantIndex: 0, wantSize: "cax11"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Autoscaler{cfg: cfg{MinSize: tt.minSize, MaxSize: tt.maxSize}}
+			index, size, err := a.getNewSize(tt.current, tt.action, tt.sizes)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("getNewSize(%d, %d) error = %v, want %v", tt.current, tt.action, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if index != tt.wantIndex || size != tt.wantSize {
+				t.Errorf("getNewSize(%d, %d) = %d, %q, want %d, %q", tt.current, tt.action, index, size, tt.wantIndex, tt.wantSize)
+			}
+		})
+	}
+}
+
+func TestGetCurrentSizeEmptyLadder(t *testing.T) {
+	a, _, _, _ := newTestAutoscaler(t, AutoScalerConfig{AllowedSizes: []string{"cx22", "cx32"}})
+	_, _, err := a.getCurrentSize(context.Background())
+	if !errors.Is(err, ErrNoAllowedSizes) {
+		t.Fatalf("getCurrentSize() error = %v, want %v", err, ErrNoAllowedSizes)
+	}
+	for _, want := range []string{"arm", "fsn1"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("getCurrentSize() error = %q, want it to name %q", err, want)
+		}
+	}
+}
+
+func TestWaitForServerToBeEmpty(t *testing.T) {
+	tests := []struct {
+		name          string
+		players       []int
+		confirmations int
+		wantErr       bool
+		wantReads     int
+	}{
+		{name: "empty straight away", players: []int{0}, confirmations: 1, wantReads: 1},
+		{name: "empties", players: []int{3, 1, 0}, confirmations: 1, wantReads: 3},
+		{name: "flickering count resets the confirmations", players: []int{0, 2, 0, 0, 1, 0, 0, 0}, confirmations: 3, wantReads: 8},
+		{name: "never empty for long enough", players: []int{0, 1}, confirmations: 2, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reads := 0
+			playerCount := func(ctx context.Context) (int, error) {
+				count := tt.players[reads%len(tt.players)]
+				reads++
+				return count, nil
+			}
+			err := waitForServerToBeEmpty(context.Background(), playerCount, 50*time.Millisecond, time.Millisecond, tt.confirmations, nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("waitForServerToBeEmpty() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && reads != tt.wantReads {
+				t.Errorf("waitForServerToBeEmpty() read the player count %d times, want %d", reads, tt.wantReads)
+			}
+		})
+	}
+}
+
+func TestIdleHoldOnlyAppliesToRules(t *testing.T) {
+	rules := []ScaleRule{{Query: "players < 1", Action: -1}}
+	a, provider, mcMetrics, controller := newTestAutoscaler(t, AutoScalerConfig{Rules: rules, ScaleDownEmptyFor: 10 * time.Minute})
+	mcMetrics.values["players < 1"] = vector(0)
+	controller.players = []int{2}
+	if err := a.CoreLoop(context.Background()); err != nil {
+		t.Fatalf("CoreLoop() error = %v", err)
+	}
+	if got := provider.Resizes(); len(got) != 0 {
+		t.Fatalf("rule scaled down to %v before the server was idle", got)
+	}
+
+	controller.players = []int{0}
+	if err := a.DoScale(context.Background(), -1); err != nil {
+		t.Fatalf("DoScale() error = %v", err)
+	}
+	if got := provider.Resizes(); len(got) != 1 || got[0] != "cax11" {
+		t.Errorf("resizes = %v, want [cax11]", got)
+	}
+}
+
+func TestCoreLoopEnforcesPinnedSize(t *testing.T) {
+	rules := []ScaleRule{{Query: "players < 1", Action: -1}}
+	a, provider, mcMetrics, _ := newTestAutoscaler(t, AutoScalerConfig{Rules: rules})
+	mcMetrics.values["players < 1"] = vector(0)
+	a.pinMux.Lock()
+	a.pinUntil, a.pinnedSize = a.Clock.Now().Add(time.Hour), "cax41"
+	a.pinMux.Unlock()
+	for range 2 {
+		if err := a.CoreLoop(context.Background()); err != nil {
+			t.Fatalf("CoreLoop() error = %v", err)
+		}
+	}
+	if got := provider.Resizes(); len(got) != 1 || got[0] != "cax41" {
+		t.Errorf("resizes = %v, want [cax41]", got)
+	}
+}
+
+func TestCheckSizeBounds(t *testing.T) {
+	tests := []struct {
+		name             string
+		minSize, maxSize string
+		wantErr          bool
+	}{
+		{name: "unset"},
+		{name: "min only", minSize: "cax21"},
+		{name: "min below max", minSize: "cax21", maxSize: "cax31"},
+		{name: "min equals max", minSize: "cax31", maxSize: "cax31"},
+		{name: "min above max", minSize: "cax41", maxSize: "cax21", wantErr: true},
+		{name: "unavailable size", maxSize: "cx52", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, _, _, _ := newTestAutoscaler(t, AutoScalerConfig{MinSize: tt.minSize, MaxSize: tt.maxSize})
+			if err := a.CheckSizeBounds(context.Background()); (err != nil) != tt.wantErr {
+				t.Errorf("CheckSizeBounds() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// Run with -race: the last scale is read by metrics and holds while a scale finishes.
+func TestLastScaleConcurrentAccess(t *testing.T) {
+	a, _, _, _ := newTestAutoscaler(t, AutoScalerConfig{PostScaleUpHold: time.Hour})
+	done := make(chan error)
+	go func() { done <- a.DoScale(context.Background(), 1) }()
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("DoScale() error = %v", err)
+			}
+			if _, direction := a.lastScale(); direction != 1 {
+				t.Errorf("last scale direction = %d, want 1", direction)
+			}
+			return
+		default:
+			a.updateSelfMetrics()
+			a.inPostScaleUpHold(-1)
+		}
+	}
+}
+
+func TestCircuitOpenGaugeClosesAfterCooldown(t *testing.T) {
+	clock := NewFakeClock(time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC))
+	a, provider, _, _ := newTestAutoscaler(t, AutoScalerConfig{Clock: clock, CircuitBreakerThreshold: 1, CircuitBreakerCooldown: time.Hour})
+	provider.resizeErr = errors.New("server is locked")
+	if err := a.DoScale(context.Background(), 1); err == nil {
+		t.Fatal("DoScale() succeeded, want an error")
+	}
+	if got := testutil.ToFloat64(a.SelfMetrics.CircuitOpen); got != 1 {
+		t.Fatalf("circuit open = %v after the failure, want 1", got)
+	}
+	clock.Advance(time.Hour)
+	a.updateSelfMetrics()
+	if got := testutil.ToFloat64(a.SelfMetrics.CircuitOpen); got != 0 {
+		t.Errorf("circuit open = %v after the cooldown, want 0", got)
+	}
+}
+
+func TestCoreLoopReconcilesUnknownSizeLast(t *testing.T) {
+	tests := []struct {
+		name        string
+		pinned      string
+		warmup      bool
+		wantResizes []string
+	}{
+		{name: "scales to the nearest allowed size", wantResizes: []string{"cax31"}},
+		{name: "pin decides the size", pinned: "cax41", wantResizes: []string{"cax41"}},
+		{name: "not during warmup", warmup: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, provider, _, _ := newTestAutoscaler(t, AutoScalerConfig{
+				AllowedSizes:         []string{"cax11", "cax31", "cax41"},
+				OnUnknownCurrentSize: UnknownSizeScaleToNearest,
+			})
+			if tt.pinned != "" {
+				a.pinMux.Lock()
+				a.pinUntil, a.pinnedSize = a.Clock.Now().Add(time.Hour), tt.pinned
+				a.pinMux.Unlock()
+			}
+			if tt.warmup {
+				a.lastScaleMux.Lock()
+				a.warmupUntil = a.Clock.Now().Add(time.Hour)
+				a.lastScaleMux.Unlock()
+			}
+			if err := a.CoreLoop(context.Background()); err != nil {
+				t.Fatalf("CoreLoop() error = %v", err)
+			}
+			if got := provider.Resizes(); !slices.Equal(got, tt.wantResizes) {
+				t.Errorf("resizes = %v, want %v", got, tt.wantResizes)
+			}
+		})
+	}
+}
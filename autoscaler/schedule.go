@@ -2,6 +2,8 @@ package autoscaler
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log/slog"
 	"strconv"
 	"strings"
@@ -10,61 +12,115 @@ import (
 )
 
 type ScaleSchedule struct {
-	Cron   string `toml:"cron"`
-	Action int    `toml:"action"`
-	IfSize string `toml:"if_size"`
+	Name        string `toml:"name" yaml:"name"`
+	Description string `toml:"description" yaml:"description"`
+	Cron        string `toml:"cron" yaml:"cron"`
+	Action      int    `toml:"action" yaml:"action"`
+	IfSize      string `toml:"if_size" yaml:"if_size"`
+	// Defaults to true. Disabled schedules are kept in config but never run.
+	Enabled *bool `toml:"enabled" yaml:"enabled"`
 
 	a   *Autoscaler
 	ctx context.Context
 }
 
-func (a *Autoscaler) SetupSchedule(ctx context.Context) {
+// SetupSchedule runs the schedules and pre-scales until ctx is done. It fails if a
+// schedule's cron is invalid, though the valid ones still run.
+func (a *Autoscaler) SetupSchedule(ctx context.Context) error {
+	err := a.startSchedule(ctx)
+	go func() {
+		<-ctx.Done()
+		a.rulesMux.RLock()
+		c := a.cron
+		a.rulesMux.RUnlock()
+		c.Stop()
+	}()
+	return err
+}
+
+// startSchedule runs the schedules and pre-scales on a new cron, skipping
+// schedules whose cron is invalid.
+func (a *Autoscaler) startSchedule(ctx context.Context) error {
+	a.rulesMux.Lock()
+	defer a.rulesMux.Unlock()
 	a.cron = cron.New()
+	var errs []error
 	for i := range a.Schedule {
 		sch := &a.Schedule[i]
+		if !sch.IsEnabled() {
+			slog.Debug("skipping disabled schedule", slog.String("name", sch.DisplayName()))
+			continue
+		}
 		sch.a = a
 		sch.ctx = ctx
-		a.cron.AddJob(sch.Cron, sch)
-		slog.Debug("loaded schedule", slog.Any("schedule", sch))
+		if _, err := a.cron.AddJob(sch.Cron, sch); err != nil {
+			errs = append(errs, fmt.Errorf("invalid cron %q for schedule %s: %w", sch.Cron, sch.DisplayName(), err))
+			continue
+		}
+		slog.Debug("loaded schedule", slog.String("name", sch.DisplayName()), slog.Any("schedule", sch))
 	}
+	a.setupPreScales(ctx)
 	a.cron.Start()
-	go func() {
-		<-ctx.Done()
-		a.cron.Stop()
-	}()
+	return errors.Join(errs...)
+}
+
+func (s *ScaleSchedule) IsEnabled() bool {
+	return s.Enabled == nil || *s.Enabled
+}
+
+// DisplayName returns the schedule's name, or its cron expression if it has none.
+func (s *ScaleSchedule) DisplayName() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return s.Cron
 }
 
 func (s *ScaleSchedule) Run() {
-	slog.Info("considering scheduled scale", slog.Any("schedule", s))
+	logger := s.a.Logger.With(slog.String("schedule", s.DisplayName()))
+	logger.Info("considering scheduled scale", slog.Any("schedule", s))
 	ctx := s.ctx
+	maintenance, err := s.a.inMaintenance(ctx)
+	if err != nil {
+		logger.Error("failed to check maintenance mode", slog.String("err", err.Error()))
+		return
+	}
+	if maintenance {
+		logger.Info("maintenance mode active, not scaling", slog.String("query", s.a.MaintenanceQuery))
+		return
+	}
 	current, sizes, err := s.a.getCurrentSize(ctx)
 	if err != nil {
-		s.a.Logger.Error("failed to get current size", slog.String("err", err.Error()))
+		logger.Error("failed to get current size", slog.String("err", err.Error()))
 		return
 	}
 	if s.IfSize != "" {
 		if !s.evaluateIfSize(current) {
-			s.a.Logger.Info("not scaling because IfSize condition not met")
+			logger.Info("not scaling because IfSize condition not met")
 			return
 		}
 	}
 
-	ok, err := s.a.CanScale(ctx, s.Action)
+	ok, reason, err := s.a.CanScale(ctx, s.Action)
 	if err != nil {
-		s.a.Logger.Error("failed to check if can scale", slog.String("err", err.Error()))
+		logger.Error("failed to check if can scale", slog.String("err", err.Error()))
 		return
 	}
 	if !ok {
-		s.a.Logger.Debug("not scaling because no eligible size")
+		logger.Debug("not scaling", slog.String("reason", string(reason)))
 		return
 	}
 
-	_, newSize := s.a.getNewSize(current, s.Action, sizes)
-	s.a.Logger.Info("scheduled scale", slog.String("current", sizes[current]), slog.String("new", newSize))
+	_, newSize, err := s.a.getNewSize(current, s.Action, sizes)
+	if err != nil {
+		logger.Error("failed to get new size", slog.String("err", err.Error()))
+		return
+	}
+	logger.Info("scheduled scale", slog.String("current", sizes[current]), slog.String("new", newSize))
 
-	err = s.a.DoScale(ctx, s.Action)
+	err = s.a.doScale(ctx, scaleRequest{direction: s.Action, trigger: "schedule: " + s.DisplayName()})
 	if err != nil {
-		s.a.Logger.Error("failed to scale", slog.String("err", err.Error()))
+		logger.Error("failed to scale", slog.String("err", err.Error()))
 		return
 	}
 }
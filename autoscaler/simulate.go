@@ -0,0 +1,64 @@
+package autoscaler
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// Simulate replays the rules and schedules over [from, to], running the core loop
+// every step with a FakeClock and Prometheus queries set to that time, and returns
+// the scaling actions that would have been attempted. Cooldowns and holds apply as
+// they would have then. The Scaler and Controller should be stand-ins that don't
+// touch the real server. Pre-scales and alerts are not replayed.
+func (a *Autoscaler) Simulate(ctx context.Context, from, to time.Time, step time.Duration) []ScaleEvent {
+	clock := NewFakeClock(from)
+	realClock := a.Clock
+	a.Clock = clock
+	a.Metrics.SetNow(clock.Now)
+	defer func() {
+		a.Clock = realClock
+		a.Metrics.SetNow(nil)
+	}()
+
+	type scheduled struct {
+		schedule *ScaleSchedule
+		cron     cron.Schedule
+	}
+	var schedules []scheduled
+	rules := a.CurrentRules()
+	for i := range rules.Schedule {
+		sch := &rules.Schedule[i]
+		if !sch.IsEnabled() {
+			continue
+		}
+		sch.a, sch.ctx = a, ctx
+		parsed, err := cron.ParseStandard(sch.Cron)
+		if err != nil {
+			a.Logger.Warn("not simulating schedule with invalid cron", slog.String("schedule", sch.DisplayName()), slog.String("error", err.Error()))
+			continue
+		}
+		schedules = append(schedules, scheduled{sch, parsed})
+	}
+
+	prev := from.Add(-step)
+	// The loop can move the clock on itself, e.g. while settling between steps of a scale-down.
+	for now := from; !now.After(to); now = clock.Now().Add(step) {
+		clock.Set(now)
+		if ctx.Err() != nil {
+			break
+		}
+		for _, s := range schedules {
+			if !s.cron.Next(prev).After(now) {
+				s.schedule.Run()
+			}
+		}
+		if err := a.CoreLoop(ctx); err != nil {
+			a.Logger.Info("simulated loop failed", slog.Time("at", now), slog.String("error", err.Error()))
+		}
+		prev = now
+	}
+	return a.RecentEvents()
+}
@@ -0,0 +1,121 @@
+package autoscaler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// TimeWindowRule keeps the server at a given size between two times of day.
+// Unlike a ScaleSchedule it is enforced for as long as the window is active.
+type TimeWindowRule struct {
+	Name  string `toml:"name" yaml:"name"`
+	Start string `toml:"start" yaml:"start"` // e.g. "00:00"
+	End   string `toml:"end" yaml:"end"`     // e.g. "06:00"; if before Start, the window finishes the next day
+	// Days of the week the window starts on, by full name or three-letter
+	// abbreviation (e.g. ["mon", "tuesday"]); every day if empty.
+	Days []string `toml:"days" yaml:"days"`
+	Size string   `toml:"size" yaml:"size"`
+
+	start, end time.Duration
+	days       []time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseWeekday parses a day's full name or three-letter abbreviation, in any case.
+func parseWeekday(s string) (time.Weekday, bool) {
+	s = strings.ToLower(s)
+	if wd, ok := weekdays[s]; ok {
+		return wd, true
+	}
+	for _, wd := range weekdays {
+		if s == strings.ToLower(wd.String()) {
+			return wd, true
+		}
+	}
+	return 0, false
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q: %w", s, err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Compile parses the window's times and days.
+func (w *TimeWindowRule) Compile() error {
+	var err error
+	if w.start, err = parseClock(w.Start); err != nil {
+		return err
+	}
+	if w.end, err = parseClock(w.End); err != nil {
+		return err
+	}
+	if w.Size == "" {
+		return fmt.Errorf("time window %q has no size", w.Name)
+	}
+	w.days = nil
+	for _, d := range w.Days {
+		wd, ok := parseWeekday(d)
+		if !ok {
+			return fmt.Errorf("invalid day %q", d)
+		}
+		w.days = append(w.days, wd)
+	}
+	return nil
+}
+
+// Active reports whether t falls inside the window.
+func (w *TimeWindowRule) Active(t time.Time) bool {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	sinceMidnight := t.Sub(midnight)
+	startsOn := func(day time.Weekday) bool {
+		return len(w.days) == 0 || slices.Contains(w.days, day)
+	}
+	if w.start <= w.end {
+		return startsOn(t.Weekday()) && sinceMidnight >= w.start && sinceMidnight < w.end
+	}
+	// The window wraps past midnight: we're either in today's window, or the tail of yesterday's.
+	if sinceMidnight >= w.start {
+		return startsOn(t.Weekday())
+	}
+	return sinceMidnight < w.end && startsOn(midnight.AddDate(0, 0, -1).Weekday())
+}
+
+// enforceTimeWindows scales to the size of the first active time window, if any,
+// and reports whether one was active.
+func (a *Autoscaler) enforceTimeWindows(ctx context.Context) (bool, error) {
+	now := a.Clock.Now()
+	windows := a.CurrentRules().TimeWindows
+	for i := range windows {
+		w := &windows[i]
+		if !w.Active(now) {
+			continue
+		}
+		current, sizes, err := a.getCurrentSize(ctx)
+		if err != nil {
+			return true, fmt.Errorf("failed to get current size: %w", err)
+		}
+		if sizes[current] == w.Size {
+			a.Logger.Debug("time window active, already at its size", slog.String("window", w.Name), slog.String("size", w.Size))
+			return true, nil
+		}
+		a.Logger.Info("time window active, scaling to its size", slog.String("window", w.Name), slog.String("current", sizes[current]), slog.String("target", w.Size))
+		return true, a.doScale(ctx, scaleRequest{target: w.Size, trigger: "time window: " + w.Name})
+	}
+	return false, nil
+}
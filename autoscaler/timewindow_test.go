@@ -0,0 +1,35 @@
+package autoscaler
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTimeWindowRuleCompileDays(t *testing.T) {
+	tests := []struct {
+		days    []string
+		want    []time.Weekday
+		wantErr bool
+	}{
+		{days: nil},
+		{days: []string{"mon", "Tue", "WED"}, want: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday}},
+		{days: []string{"saturday", "Sunday"}, want: []time.Weekday{time.Saturday, time.Sunday}},
+		{days: []string{"monkey"}, wantErr: true},
+		{days: []string{"sunburn"}, wantErr: true},
+		{days: []string{"th"}, wantErr: true},
+		{days: []string{"thurs"}, wantErr: true},
+		{days: []string{""}, wantErr: true},
+	}
+	for _, tt := range tests {
+		w := TimeWindowRule{Name: "test", Start: "10:00", End: "12:00", Size: "cax31", Days: tt.days}
+		err := w.Compile()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Compile() with days %q error = %v, wantErr %v", tt.days, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !slices.Equal(w.days, tt.want) {
+			t.Errorf("Compile() with days %q = %v, want %v", tt.days, w.days, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"reflect"
+	"time"
+)
+
+// printConfig writes the resolved options as JSON, with secrets redacted.
+func printConfig(w io.Writer, args Options) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(redacted(reflect.ValueOf(args)))
+}
+
+// LogValue logs the options with secrets redacted.
+func (o Options) LogValue() slog.Value {
+	return slog.AnyValue(redacted(reflect.ValueOf(o)))
+}
+
+// redacted converts v to plain values, replacing fields tagged redact:"" that
+// are set, and skipping subcommands.
+func redacted(v reflect.Value) any {
+	if d, ok := v.Interface().(time.Duration); ok {
+		return d.String()
+	}
+	if v.Kind() != reflect.Struct {
+		return v.Interface()
+	}
+	m := make(map[string]any)
+	for i := range v.NumField() {
+		f := v.Type().Field(i)
+		if _, cmd := f.Tag.Lookup("cmd"); cmd || !f.IsExported() {
+			continue
+		}
+		if _, redact := f.Tag.Lookup("redact"); redact && !v.Field(i).IsZero() {
+			m[f.Name] = "REDACTED"
+			continue
+		}
+		m[f.Name] = redacted(v.Field(i))
+	}
+	return m
+}
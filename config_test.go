@@ -0,0 +1,28 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestOptionsLogValueRedactsSecrets(t *testing.T) {
+	var args Options
+	args.Scaler.Hetzner.APIKey = "hcloud-secret"
+	args.Metrics.Password = "prometheus-secret"
+	args.Scaler.AllowedServerSizes = []string{"cax11", "cax21"}
+	var buf bytes.Buffer
+	slog.New(slog.NewJSONHandler(&buf, nil)).Info("resolved options", slog.Any("options", args))
+	out := buf.String()
+	for _, secret := range []string{"hcloud-secret", "prometheus-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("logged options contain %q: %s", secret, out)
+		}
+	}
+	for _, want := range []string{"REDACTED", "cax21"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("logged options don't contain %q: %s", want, out)
+		}
+	}
+}
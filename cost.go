@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markspolakovs/mcas/autoscaler"
+)
+
+// cost estimates the monthly cost of autoscaling and compares it with running at a
+// fixed size. With Prometheus history, it replays the rules and schedules over it
+// as simulate does. A --cost.profile has nothing to evaluate rules against, so each
+// hour of it gets the smallest size whose capacity fits its players instead.
+func cost(args Options, logger *slog.Logger) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var provider autoscaler.Provider
+	var shares map[string]float64
+	if len(args.Cost.Profile) > 0 {
+		if len(args.Cost.Profile) != 24 {
+			return fmt.Errorf("--profile needs 24 values, got %d", len(args.Cost.Profile))
+		}
+		if len(args.Scaler.SizeCapacity) == 0 {
+			return fmt.Errorf("--scaler.size-capacity is required to estimate sizes from --profile")
+		}
+		var err error
+		provider, _, err = newProvider(args)
+		if err != nil {
+			return err
+		}
+		sizes, err := costLadder(ctx, args, provider)
+		if err != nil {
+			return err
+		}
+		shares = make(map[string]float64)
+		for _, players := range args.Cost.Profile {
+			shares[autoscaler.FitSize(sizes, args.Scaler.SizeCapacity, players)] += 1.0 / 24
+		}
+	} else {
+		step := args.Cost.Step
+		if step <= 0 {
+			step = args.Interval
+		}
+		end := time.Now()
+		from := end.Add(-args.Cost.History)
+		a, simulated, err := newSimulatedAutoscaler(ctx, args, logger, args.Cost.StartSize, int(args.Cost.History/step)+1)
+		if err != nil {
+			return err
+		}
+		provider = simulated.Provider
+		start := simulated.size
+		shares = timeShares(start, from, end, a.Simulate(ctx, from, end, step))
+	}
+
+	sizes, err := costLadder(ctx, args, provider)
+	if err != nil {
+		return err
+	}
+	baseline := args.Cost.Baseline
+	if baseline == "" {
+		baseline = sizes[len(sizes)-1]
+	}
+	// The server may have spent time at sizes outside the ladder, e.g. where it started.
+	for size := range shares {
+		if !slices.Contains(sizes, size) {
+			sizes = append(sizes, size)
+		}
+	}
+
+	prices := make(map[string]float64)
+	for _, size := range append(slices.Clone(sizes), baseline) {
+		prices[size], err = provider.GetPrice(ctx, size)
+		if err != nil {
+			return fmt.Errorf("failed to get price of %s: %w", size, err)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SIZE\tSHARE\tHOURLY\tMONTHLY")
+	var total float64
+	for _, size := range sizes {
+		share := shares[size]
+		monthly := share * prices[size] * autoscaler.HoursPerMonth
+		total += monthly
+		fmt.Fprintf(w, "%s\t%.1f%%\t%.4f\t%.2f\n", size, share*100, prices[size], monthly)
+	}
+	fixed := prices[baseline] * autoscaler.HoursPerMonth
+	fmt.Fprintf(w, "autoscaled\t\t\t%.2f\n", total)
+	fmt.Fprintf(w, "fixed at %s\t\t\t%.2f\n", baseline, fixed)
+	if fixed > 0 {
+		fmt.Fprintf(w, "saving\t%.1f%%\t\t%.2f\n", (fixed-total)/fixed*100, fixed-total)
+	}
+	return w.Flush()
+}
+
+// costLadder returns the allowed sizes the server can be resized to, between --scaler.min-size and --scaler.max-size.
+func costLadder(ctx context.Context, args Options, provider autoscaler.Provider) ([]string, error) {
+	available, err := provider.GetAvailableSizes(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get available sizes: %w", err)
+	}
+	sizes := slices.DeleteFunc(available, func(s string) bool {
+		return !slices.Contains(args.Scaler.AllowedServerSizes, s)
+	})
+	if len(sizes) == 0 {
+		return nil, fmt.Errorf("none of the allowed sizes are available")
+	}
+	if i := slices.Index(sizes, args.Scaler.MaxSize); i != -1 {
+		sizes = sizes[:i+1]
+	}
+	if i := slices.Index(sizes, args.Scaler.MinSize); i != -1 {
+		sizes = sizes[i:]
+	}
+	return sizes, nil
+}
+
+// timeShares returns the fraction of [from, to] spent at each size, starting at
+// start and changing with each successful scale in events.
+func timeShares(start string, from, to time.Time, events []autoscaler.ScaleEvent) map[string]float64 {
+	total := to.Sub(from).Seconds()
+	shares := make(map[string]float64)
+	size, since := start, from
+	for _, e := range events {
+		if e.Outcome != "success" {
+			continue
+		}
+		shares[size] += e.Time.Sub(since).Seconds() / total
+		size, since = e.To, e.Time
+	}
+	shares[size] += max(to.Sub(since).Seconds(), 0) / total
+	return shares
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/markspolakovs/mcas/autoscaler"
+)
+
+func TestTimeShares(t *testing.T) {
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(10 * time.Hour)
+	events := []autoscaler.ScaleEvent{
+		{Time: from.Add(2 * time.Hour), From: "cax11", To: "cax21", Outcome: "success"},
+		{Time: from.Add(3 * time.Hour), From: "cax21", To: "cax11", Outcome: "refused"},
+		{Time: from.Add(7 * time.Hour), From: "cax21", To: "cax11", Outcome: "success"},
+	}
+	got := timeShares("cax11", from, to, events)
+	want := map[string]float64{"cax11": 0.5, "cax21": 0.5}
+	if len(got) != len(want) {
+		t.Fatalf("timeShares() = %v, want %v", got, want)
+	}
+	for size, share := range want {
+		if math.Abs(got[size]-share) > 1e-9 {
+			t.Errorf("timeShares()[%s] = %v, want %v", size, got[size], share)
+		}
+	}
+}
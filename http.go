@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/markspolakovs/mcas/autoscaler"
+	"github.com/markspolakovs/mcas/metrics"
+)
+
+func newHTTPHandler(ctx context.Context, a *autoscaler.Autoscaler, selfMetrics *metrics.SelfMetrics) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", selfMetrics.Handler())
+	mux.HandleFunc("/config", func(w http.ResponseWriter, r *http.Request) {
+		rs := a.CurrentRules()
+		writeJSON(w, map[string]any{
+			"rules":       rs.Rules,
+			"schedule":    rs.Schedule,
+			"time_window": rs.TimeWindows,
+			"pre_scale":   rs.PreScales,
+			"alert":       rs.Alerts,
+		})
+	})
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, map[string]any{
+			"recent_events": a.RecentEvents(),
+			"provider":      a.ProviderHealth(),
+		})
+	})
+	// POST /scale?direction=1 or ?size=cax31 triggers a scale in the background.
+	mux.HandleFunc("POST /scale", func(w http.ResponseWriter, r *http.Request) {
+		size := r.URL.Query().Get("size")
+		direction, err := strconv.Atoi(r.URL.Query().Get("direction"))
+		if size == "" && (err != nil || direction == 0) {
+			http.Error(w, "invalid or missing direction or size", http.StatusBadRequest)
+			return
+		}
+		if size == "" {
+			ok, reason, err := a.CanScale(r.Context(), direction)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !ok {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				writeJSON(w, map[string]any{"reason": reason})
+				return
+			}
+		}
+		go func() {
+			var err error
+			if size != "" {
+				err = a.ScaleTo(ctx, size)
+			} else {
+				err = a.DoScale(ctx, direction)
+			}
+			if err != nil {
+				slog.Error("manual scale failed", slog.String("error", err.Error()))
+			}
+		}()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	// POST /alert receives Alertmanager webhooks, scaling for alerts mapped in the rules file.
+	mux.HandleFunc("POST /alert", func(w http.ResponseWriter, r *http.Request) {
+		var payload struct {
+			Alerts []struct {
+				Status      string            `json:"status"`
+				Labels      map[string]string `json:"labels"`
+				StartsAt    string            `json:"startsAt"`
+				Fingerprint string            `json:"fingerprint"`
+			} `json:"alerts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			http.Error(w, "invalid alertmanager payload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		for _, alert := range payload.Alerts {
+			name, firing := alert.Labels["alertname"], alert.Status == "firing"
+			key := alert.Fingerprint
+			if key == "" {
+				key = name + "@" + alert.StartsAt
+			}
+			go func() {
+				if err := a.HandleAlert(ctx, name, key, firing); err != nil {
+					slog.Error("failed to scale for alert", slog.String("alert", name), slog.String("error", err.Error()))
+				}
+			}()
+		}
+		w.WriteHeader(http.StatusAccepted)
+	})
+	// POST /pin?duration=2h[&size=cax31] suspends all automatic scaling, optionally
+	// after moving to the given size. DELETE /pin lifts it.
+	mux.HandleFunc("POST /pin", func(w http.ResponseWriter, r *http.Request) {
+		d, err := time.ParseDuration(r.URL.Query().Get("duration"))
+		if err != nil || d <= 0 {
+			http.Error(w, "invalid or missing duration", http.StatusBadRequest)
+			return
+		}
+		until, err := a.Pin(ctx, r.URL.Query().Get("size"), d)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, map[string]any{"pinned_until": until})
+	})
+	mux.HandleFunc("DELETE /pin", func(w http.ResponseWriter, r *http.Request) {
+		a.Unpin()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+type httpAuth struct {
+	username    string
+	password    string
+	bearerToken string
+}
+
+// requireAuth rejects requests that don't carry the configured credentials. If none are configured, all requests are allowed.
+func requireAuth(auth httpAuth, next http.Handler) http.Handler {
+	if auth.bearerToken == "" && auth.username == "" {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok := false
+		if auth.bearerToken != "" {
+			token, found := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			ok = found && subtle.ConstantTimeCompare([]byte(token), []byte(auth.bearerToken)) == 1
+		}
+		if !ok && auth.username != "" {
+			u, p, found := r.BasicAuth()
+			ok = found &&
+				subtle.ConstantTimeCompare([]byte(u), []byte(auth.username)) == 1 &&
+				subtle.ConstantTimeCompare([]byte(p), []byte(auth.password)) == 1
+		}
+		if !ok {
+			if auth.username != "" {
+				w.Header().Set("WWW-Authenticate", `Basic realm="mcas"`)
+			}
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Warn("failed to write response", slog.String("error", err.Error()))
+	}
+}
+
+func serveHTTP(ctx context.Context, address string, handler http.Handler) {
+	srv := &http.Server{Addr: address, Handler: handler}
+	go func() {
+		slog.Info("http server starting", slog.String("address", address))
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("http server error", slog.String("error", fmt.Sprint(err)))
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		srv.Close()
+	}()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// stdinConfirmer asks the operator to confirm each scaling action on stdin.
+type stdinConfirmer struct {
+	lines   chan string
+	timeout time.Duration
+}
+
+func newStdinConfirmer(r io.Reader, timeout time.Duration) *stdinConfirmer {
+	c := &stdinConfirmer{
+		lines:   make(chan string),
+		timeout: timeout,
+	}
+	// A single reader goroutine, so a prompt that times out doesn't leave a read
+	// pending that swallows the answer to the next one.
+	go func() {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			c.lines <- scanner.Text()
+		}
+		close(c.lines)
+	}()
+	return c
+}
+
+func (c *stdinConfirmer) Confirm(ctx context.Context, proposal string) bool {
+	// Discard anything typed while no prompt was showing.
+	for drained := false; !drained; {
+		select {
+		case <-c.lines:
+		default:
+			drained = true
+		}
+	}
+	fmt.Printf("%s - proceed? [y/N] (no in %s): ", proposal, c.timeout)
+	select {
+	case line, ok := <-c.lines:
+		if !ok {
+			return false
+		}
+		answer := strings.ToLower(strings.TrimSpace(line))
+		return answer == "y" || answer == "yes"
+	case <-time.After(c.timeout):
+		fmt.Println()
+		slog.Info("no answer, not scaling", slog.String("proposal", proposal))
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func dryRunConfirm(ctx context.Context, proposal string) bool {
+	slog.Info("dry run, not scaling", slog.String("proposal", proposal))
+	return false
+}
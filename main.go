@@ -2,121 +2,823 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/alecthomas/kong"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/natefinch/lumberjack.v2"
+	"gopkg.in/yaml.v3"
 
 	"github.com/markspolakovs/mcas/autoscaler"
+	"github.com/markspolakovs/mcas/mcstatus"
 	"github.com/markspolakovs/mcas/metrics"
+	"github.com/markspolakovs/mcas/notify"
+	"github.com/markspolakovs/mcas/providers/azure"
 	"github.com/markspolakovs/mcas/providers/hcloud"
 
 	_ "github.com/joho/godotenv/autoload"
 )
 
 type Options struct {
-	LogLevel            slog.Level    `help:"Log level" default:"info" env:"LOG_LEVEL"`
-	Interval            time.Duration `help:"Interval between checks" default:"1m" env:"INTERVAL"`
-	MinTimeBetweenScale time.Duration `help:"Minimum time between scaling" default:"1h" env:"MIN_TIME_BETWEEN_SCALE"`
-	RulesFile           string        `help:"Path to the rules file" env:"RULES_FILE"`
-	Scaler              struct {
-		AllowedServerSizes []string `help:"List of allowed server sizes" env:"ALLOWED_SIZES"`
-		PreShutdownMessage string   `help:"Message to send to players before shutdown" env:"PRE_SHUTDOWN_MESSAGE" default:"Server is eligible for re-sizing. The server will be stopped and resized once nobody is online. The sizing will take a few minutes. If the server is not empty within the next 5 minutes, the re-sizing will be cancelled."`
-		Hetzner            struct {
-			APIKey               string        `env:"API_KEY"`
-			ServerName           string        `env:"SERVER_NAME"`
-			ServerTypesCacheTime time.Duration `help:"Server types cache time" default:"10m" env:"SERVER_TYPES_CACHE_TIME"`
+	Run       struct{} `cmd:"" default:"1" help:"Run the autoscaler"`
+	Version   struct{} `cmd:"" help:"Print version information and exit"`
+	Preflight struct{} `cmd:"" help:"Check that mcas can reach everything it needs, then exit"`
+	Cost      struct {
+		Profile   []float64     `help:"Expected players online in each hour of the day (24 values, from midnight UTC), fitted to --scaler.size-capacity instead of replaying the rules against Prometheus history"`
+		History   time.Duration `help:"How much Prometheus history to replay the rules and schedules over" default:"168h"`
+		Step      time.Duration `help:"Time between replayed loops (default: --interval)"`
+		StartSize string        `help:"Size the server was at the start of the history (default: its current size)"`
+		Baseline  string        `help:"Fixed size to compare against (default: the largest size mcas may scale to)"`
+	} `cmd:"" help:"Estimate the monthly cost of autoscaling under the rules and schedules, against a fixed size"`
+	Simulate struct {
+		From      time.Time     `help:"Start of the period to replay, in RFC 3339 format" required:""`
+		To        time.Time     `help:"End of the period to replay, in RFC 3339 format (default: now)"`
+		Step      time.Duration `help:"Time between replayed loops (default: --interval)"`
+		StartSize string        `help:"Size the server was at the start of the period (default: its current size)"`
+	} `cmd:"" help:"Replay the rules and schedules against Prometheus history and print the scaling actions they would have taken, without touching the server"`
+
+	PrintConfig             bool              `help:"Print the resolved configuration as JSON, with secrets redacted, and exit"`
+	Labels                  map[string]string `help:"Labels to add to every log line and metric, e.g. env=prod" env:"LABELS"`
+	LogLevel                slog.Level        `help:"Log level" default:"info" env:"LOG_LEVEL"`
+	LogFile                 string            `help:"Also write logs to this file, rotating it as it grows" type:"path" env:"LOG_FILE"`
+	LogMaxSize              int               `help:"Size in megabytes at which to rotate --log-file" default:"100" env:"LOG_MAX_SIZE"`
+	LogMaxBackups           int               `help:"Number of rotated log files to keep (0 to keep all)" default:"5" env:"LOG_MAX_BACKUPS"`
+	LogStderr               bool              `help:"Log to stderr (disable with --no-log-stderr to only log to --log-file)" default:"true" negatable:"" env:"LOG_STDERR"`
+	DryRun                  bool              `help:"Log scaling actions instead of carrying them out" xor:"mode" env:"DRY_RUN"`
+	Interactive             bool              `help:"Ask for confirmation on stdin before each scaling action" xor:"mode"`
+	InteractiveTimeout      time.Duration     `help:"How long to wait for confirmation before assuming no" default:"1m"`
+	Interval                time.Duration     `help:"Interval between checks" default:"1m" env:"INTERVAL"`
+	LoopTimeout             time.Duration     `help:"Give up on a check after this long (0 for no limit). A resize that has already stopped the server is always seen through, and a check that runs on regardless is reported" default:"0" env:"LOOP_TIMEOUT"`
+	MinTimeBetweenScale     time.Duration     `help:"Minimum time between scaling" default:"1h" env:"MIN_TIME_BETWEEN_SCALE"`
+	MinTimeBetweenScaleUp   time.Duration     `help:"Minimum time since the last scaling action before scaling up (0 to use --min-time-between-scale)" default:"0s" env:"MIN_TIME_BETWEEN_SCALE_UP"`
+	MinTimeBetweenScaleDown time.Duration     `help:"Minimum time since the last scaling action before scaling down (0 to use --min-time-between-scale)" default:"0s" env:"MIN_TIME_BETWEEN_SCALE_DOWN"`
+	PostScaleUpHold         time.Duration     `help:"Minimum time after scaling up before scaling down is allowed" default:"0s" env:"POST_SCALE_UP_HOLD"`
+	IdleScaleToCheapest     bool              `help:"Once the server has been empty for --scale-down-empty-for, scale-down rules go straight to the cheapest size" env:"IDLE_SCALE_TO_CHEAPEST"`
+	ScaleDownStepSettle     time.Duration     `help:"Scale down rules that move several sizes one size at a time, waiting this long between steps and stopping if the rule stops matching (0 to jump straight there)" default:"0s" env:"SCALE_DOWN_STEP_SETTLE"`
+	EmptyCheck              string            `help:"How to tell the server is empty before stopping it: by player count, or not at all (waiting --pre-shutdown-delay instead)" enum:"players,none" default:"players" env:"EMPTY_CHECK"`
+	PreShutdownDelay        time.Duration     `help:"With --empty-check=none, how long to wait after the pre-shutdown message before stopping" default:"5m" env:"PRE_SHUTDOWN_DELAY"`
+	EmptyWaitPoll           time.Duration     `help:"How often to check the player count while waiting for the server to empty before resizing" default:"5s" env:"EMPTY_WAIT_POLL"`
+	EmptyConfirmations      int               `help:"How many polls in a row must see no players before the server counts as empty" default:"1" env:"EMPTY_CONFIRMATIONS"`
+	ScaleDownEmptyFor       time.Duration     `help:"Only let rules scale down once the server has been empty for this long, checked every interval (0 to disable)" default:"0s" env:"SCALE_DOWN_EMPTY_FOR"`
+	MetricsWarmup           time.Duration     `help:"How long after a resize to ignore rules while metrics settle" default:"0s" env:"METRICS_WARMUP"`
+	RecentEvents            int               `help:"Number of recent scaling actions to keep for the status endpoint" default:"50" env:"RECENT_EVENTS"`
+	MaxActionStep           int               `help:"Largest number of sizes a single rule, schedule or alert action may move by (0 for no limit)" default:"0" env:"MAX_ACTION_STEP"`
+	RulesFile               string            `help:"Path to the rules file (TOML, or YAML if it ends in .yaml or .yml), a directory of rules files, or a glob" env:"RULES_FILE"`
+	LockFile                string            `help:"File to hold an exclusive lock on (as with flock(1)) while stopping and resizing the server" env:"LOCK_FILE"`
+	LockTimeout             time.Duration     `help:"How long to wait for --lock-file before giving up on a scaling action" default:"1m" env:"LOCK_TIMEOUT"`
+	CircuitBreaker          struct {
+		Threshold int           `help:"Number of consecutive scaling failures before scaling is suspended (0 to disable)" default:"3" env:"THRESHOLD"`
+		Cooldown  time.Duration `help:"How long to suspend scaling after repeated failures" default:"1h" env:"COOLDOWN"`
+	} `embed:"" prefix:"circuit-breaker." envprefix:"CIRCUIT_BREAKER_"`
+	Scaler struct {
+		Provider                 string         `help:"Cloud provider hosting the server" enum:"hetzner,azure" default:"hetzner" env:"PROVIDER"`
+		AllowedServerSizes       []string       `help:"List of allowed server sizes" env:"ALLOWED_SIZES"`
+		SizeCapacity             map[string]int `help:"How many players each size can hold, for fit_to_players rules, e.g. cax11=10;cax21=20" env:"SIZE_CAPACITY"`
+		MinSize                  string         `help:"Never scale below this size" env:"MIN_SIZE"`
+		MaxSize                  string         `help:"Never scale above this size" env:"MAX_SIZE"`
+		MonthlyBudget            float64        `help:"Refuse to scale up to sizes that would cost more than this per month, in the provider's currency (0 to disable)" env:"MONTHLY_BUDGET"`
+		OnUnknownSize            string         `help:"What to do if the server's current size is not an allowed size" enum:"error,scale-to-nearest-allowed,adopt" default:"error" env:"ON_UNKNOWN_SIZE"`
+		OnMetricsUnavailable     string         `help:"What to do when rules can't be evaluated because metrics are unavailable" enum:"hold,scale-to-safe-size" default:"hold" env:"ON_METRICS_UNAVAILABLE"`
+		MetricsUnavailableAfter  time.Duration  `help:"How long metrics must be unavailable before acting on --scaler.on-metrics-unavailable" default:"30m" env:"METRICS_UNAVAILABLE_AFTER"`
+		SafeSize                 string         `help:"Size to scale to when metrics are unavailable" env:"SAFE_SIZE"`
+		ProviderUnavailableAfter time.Duration  `help:"Once the cloud provider's API has been unreachable for this long, stop scaling and alert until it's back (0 to keep failing every loop)" default:"0s" env:"PROVIDER_UNAVAILABLE_AFTER"`
+		PreShutdownMessage       string         `help:"Message to send to players before shutdown" env:"PRE_SHUTDOWN_MESSAGE" default:"Server is eligible for re-sizing. The server will be stopped and resized once nobody is online. The sizing will take a few minutes. If the server is not empty within the next 5 minutes, the re-sizing will be cancelled."`
+		PreShutdownRepeatEvery   time.Duration  `help:"Repeat the pre-shutdown message this often while waiting for the server to empty, for players who join during the wait (0 to send it once)" default:"0s" env:"PRE_SHUTDOWN_REPEAT_EVERY"`
+		Hetzner                  struct {
+			APIKey                  string        `redact:"" env:"API_KEY"`
+			ServerName              string        `env:"SERVER_NAME"`
+			ServerTypesCacheTime    time.Duration `help:"Server types cache time" default:"10m" env:"SERVER_TYPES_CACHE_TIME"`
+			RestrictToLocation      string        `help:"Only offer server types available in this location (default: the server's, * for any)" env:"RESTRICT_TO_LOCATION"`
+			StopTimeout             time.Duration `help:"How long to wait for the server to power off" default:"10m" env:"STOP_TIMEOUT"`
+			StopPollMaxInterval     time.Duration `help:"Longest gap between checks while waiting for the server to power off" default:"30s" env:"STOP_POLL_MAX_INTERVAL"`
+			RestrictToArchitecture  string        `help:"Only offer server types of this architecture (default: the server's, * for any)" env:"RESTRICT_TO_ARCHITECTURE"`
+			AllowArchitectureChange bool          `help:"Allow resizing to a server type of a different architecture, which the server's image must boot on" env:"ALLOW_ARCHITECTURE_CHANGE"`
 		} `embed:"" envprefix:"HETZNER_" prefix:"hetzner."`
+		Azure struct {
+			SubscriptionID          string        `env:"SUBSCRIPTION_ID"`
+			ResourceGroup           string        `env:"RESOURCE_GROUP"`
+			VMName                  string        `env:"VM_NAME"`
+			SizesCacheTime          time.Duration `help:"VM sizes cache time" default:"1h" env:"SIZES_CACHE_TIME"`
+			StopTimeout             time.Duration `help:"How long to wait for the VM to deallocate" default:"10m" env:"STOP_TIMEOUT"`
+			AllowArchitectureChange bool          `help:"Allow resizing to a VM size of a different CPU architecture" env:"ALLOW_ARCHITECTURE_CHANGE"`
+		} `embed:"" envprefix:"AZURE_" prefix:"azure."`
 	} `embed:"" prefix:"scaler."`
 	Metrics struct {
-		Address  string `help:"Prometheus address" env:"ADDRESS"`
-		Username string `help:"Prometheus username" env:"USERNAME"`
-		Password string `help:"Prometheus password" env:"PASSWORD"`
+		Address          []string      `help:"Prometheus address; if several are given they are tried in order on failure" env:"ADDRESS"`
+		Username         string        `help:"Prometheus username" env:"USERNAME"`
+		Password         string        `help:"Prometheus password" redact:"" env:"PASSWORD"`
+		BearerToken      string        `help:"Bearer token for Prometheus" redact:"" env:"BEARER_TOKEN"`
+		BearerTokenFile  string        `help:"File containing a bearer token for Prometheus, re-read when the token is rejected" env:"BEARER_TOKEN_FILE"`
+		PlayerCountQuery string        `help:"Prometheus query for the number of online players, used instead of asking the server while waiting for it to empty" env:"PLAYER_COUNT_QUERY"`
+		MaintenanceQuery string        `help:"Prometheus query that suspends rules and schedules while it returns any results or a non-zero scalar" env:"MAINTENANCE_QUERY"`
+		CacheTTL         time.Duration `help:"Reuse results of identical queries within a loop for this long (0 to disable)" default:"0s" env:"CACHE_TTL"`
 	} `embed:"" prefix:"metrics." envprefix:"METRICS_"`
+	Notify struct {
+		WebhookURL        []string `help:"Discord/Slack-compatible webhooks to post scale events and the pre-shutdown message to; prefix with a severity, e.g. error=https://..., to only post that and worse" redact:"" env:"WEBHOOK_URL"`
+		GenericWebhookURL []string `help:"Webhooks to post events to as JSON with their severity, with the same severity prefix as --notify.webhook-url" redact:"" env:"GENERIC_WEBHOOK_URL"`
+		Grafana           struct {
+			URL   string   `help:"Base URL of a Grafana instance to record events on as annotations" env:"URL"`
+			Token string   `help:"Grafana service account token" redact:"" env:"TOKEN"`
+			Tags  []string `help:"Tags to add to Grafana annotations, as well as the event's severity" default:"mcas" env:"TAGS"`
+		} `embed:"" prefix:"grafana." envprefix:"GRAFANA_"`
+		CloudEvents struct {
+			URL    string `help:"HTTP sink to post every scaling action to as a CloudEvent (type com.mcas.scale), along with notifications (com.mcas.notification)" env:"URL"`
+			Source string `help:"CloudEvents source identifying this instance" default:"mcas" env:"SOURCE"`
+		} `embed:"" prefix:"cloudevents." envprefix:"CLOUDEVENTS_"`
+		Log          bool          `help:"Also log notifications" env:"LOG"`
+		Reloads      bool          `help:"Also post what changed when the rules are reloaded" env:"RELOADS"`
+		ClampedEvery time.Duration `help:"Post when a rule is met but the server is already at its min or max size, at most this often per rule (0 to disable)" default:"24h" env:"CLAMPED_EVERY"`
+	} `embed:"" prefix:"notify." envprefix:"NOTIFY_"`
+	Calendar struct {
+		URL     string        `help:"iCalendar (.ics) feed whose events with e.g. [mcas:cax31] in their title keep the server at that size while they are on" redact:"" env:"URL"`
+		Lead    time.Duration `help:"How long before a calendar event starts to scale up for it" default:"30m" env:"LEAD"`
+		Refresh time.Duration `help:"How often to re-fetch the calendar" default:"15m" env:"REFRESH"`
+	} `embed:"" prefix:"calendar." envprefix:"CALENDAR_"`
+	HTTP struct {
+		Address     string `help:"Address to serve mcas's own metrics and control endpoints on (disabled if empty)" env:"ADDRESS"`
+		Username    string `help:"Require this username (with --http.password) for all endpoints" env:"USERNAME"`
+		Password    string `help:"Password for --http.username" redact:"" env:"PASSWORD"`
+		BearerToken string `help:"Require this bearer token for all endpoints (basic auth is also accepted if configured)" redact:"" env:"BEARER_TOKEN"`
+	} `embed:"" prefix:"http." envprefix:"HTTP_"`
 	Minecraft struct {
-		RCON struct {
+		Controller string `help:"How to talk to the server" enum:"rcon,http" default:"rcon" env:"MINECRAFT_CONTROLLER"`
+		HTTP       struct {
+			URL   string `help:"Base URL of the server plugin's HTTP API" env:"URL"`
+			Token string `help:"Bearer token for the server plugin's HTTP API" redact:"" env:"TOKEN"`
+		} `embed:"" prefix:"http." envprefix:"MINECRAFT_HTTP_"`
+		StopCommand     string        `help:"Console command to stop the server before resizing (default: stop)" xor:"stop" env:"STOP_COMMAND"`
+		SkipStopCommand bool          `help:"Don't send a stop command and let the cloud provider's shutdown stop the server" xor:"stop" env:"SKIP_STOP_COMMAND"`
+		ReadyCommand    string        `help:"Command to poll after a resize until the server runs it, to tell that it has finished starting" default:"list" env:"READY_COMMAND"`
+		ReadyTimeout    time.Duration `help:"How long to wait for the server to run --minecraft.ready-command after a resize (0 to not wait)" default:"5m" env:"READY_TIMEOUT"`
+		PingAddress     string        `help:"Server address (e.g. mc.example.com:25565) to get the player count from with a server list ping instead of the controller, which needs no RCON password" env:"MINECRAFT_PING_ADDRESS"`
+		RCON            struct {
 			Address  string `help:"RCON address" env:"ADDRESS"`
-			Password string `help:"RCON password" env:"PASSWORD"`
+			Password string `help:"RCON password" redact:"" env:"PASSWORD"`
 		} `embed:"" prefix:"rcon." envprefix:"RCON_"`
+		Status struct {
+			Address string `help:"Address to answer server list pings on while the server is down (disabled if empty)" env:"ADDRESS"`
+			MOTD    string `help:"MOTD to show while the server is down, as plain text or a JSON text component" default:"Server is being resized, back in a few minutes" env:"MOTD"`
+		} `embed:"" prefix:"status." envprefix:"MINECRAFT_STATUS_"`
+		Metric struct {
+			Command string         `help:"Console command to run every interval to read an in-game metric for rcon_metric rules (e.g. forge entity list)" env:"COMMAND"`
+			Regex   *regexp.Regexp `help:"Regex whose last submatch in the command's output is the metric's value" default:"(\\d+(?:\\.\\d+)?)" env:"REGEX"`
+			Name    string         `help:"Name that rcon_metric rules use to refer to the metric" default:"rcon" env:"NAME"`
+		} `embed:"" prefix:"metric." envprefix:"MINECRAFT_METRIC_"`
+		Drain struct {
+			Command string `help:"Command to move players off the server before resizing (e.g. send @a lobby)" env:"COMMAND"`
+			RCON    struct {
+				Address  string `help:"RCON address to send the drain command to (defaults to the server's)" env:"ADDRESS"`
+				Password string `help:"RCON password for the drain address" redact:"" env:"PASSWORD"`
+			} `embed:"" prefix:"rcon." envprefix:"RCON_"`
+		} `embed:"" prefix:"drain." envprefix:"DRAIN_"`
 	} `embed:"" prefix:"minecraft."`
 }
 
-func loadRules(args Options) ([]autoscaler.ScaleRule, []autoscaler.ScaleSchedule, error) {
-	var data struct {
-		Rules    []autoscaler.ScaleRule     `toml:"rules"`
-		Schedule []autoscaler.ScaleSchedule `toml:"schedule"`
+// rulesFileVersion is the current version of the rules file format. Bump it when a
+// change would make existing files mean something different, and explain the upgrade
+// in checkRulesFileVersion.
+const rulesFileVersion = 1
+
+// RulesFile is the structure of the rules file.
+type RulesFile struct {
+	// The version of the format the file is written for (see rulesFileVersion).
+	Version int `toml:"version" yaml:"version"`
+	// Per-server overrides for settings that are otherwise global.
+	Server struct {
+		RconAddress  string `toml:"rcon_address" yaml:"rcon_address"`
+		RconPassword string `toml:"rcon_password" yaml:"rcon_password"`
+	} `toml:"server" yaml:"server"`
+	Rules       []autoscaler.ScaleRule      `toml:"rules" yaml:"rules"`
+	Schedule    []autoscaler.ScaleSchedule  `toml:"schedule" yaml:"schedule"`
+	TimeWindows []autoscaler.TimeWindowRule `toml:"time_window" yaml:"time_window"`
+	PreScales   []autoscaler.PreScale       `toml:"pre_scale" yaml:"pre_scale"`
+	Alerts      []autoscaler.AlertAction    `toml:"alert" yaml:"alert"`
+}
+
+// rulesPaths expands the rules file option, which may be a file, a directory of rules files, or a glob.
+func rulesPaths(pattern string) ([]string, error) {
+	if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+		var paths []string
+		for _, ext := range []string{"*.toml", "*.yaml", "*.yml"} {
+			matches, _ := filepath.Glob(filepath.Join(pattern, ext))
+			paths = append(paths, matches...)
+		}
+		if len(paths) == 0 {
+			return nil, fmt.Errorf("no rules files in %s", pattern)
+		}
+		slices.Sort(paths)
+		return paths, nil
+	} else if err == nil {
+		return []string{pattern}, nil
+	}
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rules file pattern %q: %w", pattern, err)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no rules files match %q", pattern)
+	}
+	return paths, nil
+}
+
+// decodeRulesFile decodes a YAML rules file if it has a .yaml or .yml extension, or TOML otherwise.
+func decodeRulesFile(path string) (RulesFile, error) {
+	var file RulesFile
+	switch filepath.Ext(path) {
+	case ".yaml", ".yml":
+		f, err := os.Open(path)
+		if err != nil {
+			return file, err
+		}
+		defer f.Close()
+		dec := yaml.NewDecoder(f)
+		err = dec.Decode(&file)
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+		return file, err
+	default:
+		md, err := toml.DecodeFile(path, &file)
+		if err != nil {
+			return file, err
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			keys := make([]string, len(undecoded))
+			for i, key := range undecoded {
+				keys[i] = key.String()
+			}
+			slog.Warn("ignoring unknown keys in rules file, check them for typos or settings from another version of mcas", slog.String("path", path), slog.Any("keys", keys))
+		}
+		return file, nil
+	}
+}
+
+// checkRulesFileVersion rejects rules files written for a newer version of mcas, and
+// warns about files that don't say which version they are for.
+func checkRulesFileVersion(path string, file RulesFile) error {
+	switch {
+	case file.Version == 0:
+		slog.Warn(fmt.Sprintf("rules file has no version, assuming it is for the current format: add version = %d to it to silence this warning", rulesFileVersion), slog.String("path", path))
+	case file.Version > rulesFileVersion:
+		return fmt.Errorf("rules file is version %d, but this mcas only understands up to version %d: upgrade mcas", file.Version, rulesFileVersion)
+	case file.Version < 0:
+		return fmt.Errorf("invalid rules file version %d", file.Version)
+	}
+	return nil
+}
+
+func loadRules(args Options) (*RulesFile, error) {
+	paths, err := rulesPaths(args.RulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rules file: %w", err)
+	}
+	var data RulesFile
+	scheduleFiles := make(map[string]string)
+	ruleFiles := make(map[string]string)
+	for _, path := range paths {
+		file, err := decodeRulesFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load rules file %s: %w", path, err)
+		}
+		if err := checkRulesFileVersion(path, file); err != nil {
+			return nil, fmt.Errorf("failed to load rules file %s: %w", path, err)
+		}
+		if file.Server.RconAddress != "" {
+			data.Server.RconAddress = file.Server.RconAddress
+		}
+		if file.Server.RconPassword != "" {
+			data.Server.RconPassword = file.Server.RconPassword
+		}
+		for _, sch := range file.Schedule {
+			if sch.Name == "" {
+				continue
+			}
+			if other, ok := scheduleFiles[sch.Name]; ok {
+				return nil, fmt.Errorf("duplicate schedule name %q in %s and %s", sch.Name, other, path)
+			}
+			scheduleFiles[sch.Name] = path
+		}
+		for _, rule := range file.Rules {
+			if rule.Name == "" {
+				continue
+			}
+			if other, ok := ruleFiles[rule.Name]; ok {
+				return nil, fmt.Errorf("duplicate rule name %q in %s and %s", rule.Name, other, path)
+			}
+			ruleFiles[rule.Name] = path
+		}
+		data.Rules = append(data.Rules, file.Rules...)
+		data.Schedule = append(data.Schedule, file.Schedule...)
+		data.TimeWindows = append(data.TimeWindows, file.TimeWindows...)
+		data.PreScales = append(data.PreScales, file.PreScales...)
+		data.Alerts = append(data.Alerts, file.Alerts...)
+	}
+	for i := range data.Rules {
+		if err := data.Rules[i].Compile(); err != nil {
+			return nil, fmt.Errorf("invalid rule %d: %w", i, err)
+		}
+		if m := data.Rules[i].RconMetric; m != "" && (args.Minecraft.Metric.Command == "" || m != args.Minecraft.Metric.Name) {
+			return nil, fmt.Errorf("invalid rule %d: rcon_metric %q is not configured with --minecraft.metric.command and --minecraft.metric.name", i, m)
+		}
+		if data.Rules[i].FitToPlayers && len(args.Scaler.SizeCapacity) == 0 {
+			return nil, fmt.Errorf("invalid rule %d: fit_to_players requires --scaler.size-capacity", i)
+		}
+		if target := data.Rules[i].PanicTargetSize; target != "" && !slices.Contains(args.Scaler.AllowedServerSizes, target) {
+			return nil, fmt.Errorf("invalid rule %d: panic_target_size %s is not one of the allowed sizes", i, target)
+		}
+	}
+	for i := range data.TimeWindows {
+		if err := data.TimeWindows[i].Compile(); err != nil {
+			return nil, fmt.Errorf("invalid time window %d: %w", i, err)
+		}
+		if size := data.TimeWindows[i].Size; !slices.Contains(args.Scaler.AllowedServerSizes, size) {
+			return nil, fmt.Errorf("invalid time window %d (%s): size %s is not one of the allowed sizes", i, data.TimeWindows[i].Name, size)
+		}
+	}
+	// Check schedules and pre-scales with the same parser that schedules them, so a typo fails here rather than being skipped.
+	for i, sch := range data.Schedule {
+		if _, err := cron.ParseStandard(sch.Cron); err != nil {
+			return nil, fmt.Errorf("invalid schedule %d (%s): invalid cron %q: %w", i, sch.DisplayName(), sch.Cron, err)
+		}
+	}
+	for i, p := range data.PreScales {
+		if _, err := cron.ParseStandard(p.Cron); err != nil {
+			return nil, fmt.Errorf("invalid pre-scale %d (%s): invalid cron %q: %w", i, p.Name, p.Cron, err)
+		}
+		if !slices.Contains(args.Scaler.AllowedServerSizes, p.Target) {
+			return nil, fmt.Errorf("invalid pre-scale %d (%s): target %s is not one of the allowed sizes", i, p.Name, p.Target)
+		}
+	}
+	if err := validateActions(args, &data); err != nil {
+		return nil, err
+	}
+	return &data, nil
+}
+
+// logEnabled logs how many rules and schedules are enabled and disabled.
+func logEnabled(logger *slog.Logger, rulesFile *RulesFile) {
+	var rulesEnabled, schedulesEnabled int
+	for _, rule := range rulesFile.Rules {
+		if rule.IsEnabled() {
+			rulesEnabled++
+		}
+	}
+	for i := range rulesFile.Schedule {
+		if rulesFile.Schedule[i].IsEnabled() {
+			schedulesEnabled++
+		}
+	}
+	logger.Info("loaded rules",
+		slog.Int("rulesEnabled", rulesEnabled), slog.Int("rulesDisabled", len(rulesFile.Rules)-rulesEnabled),
+		slog.Int("schedulesEnabled", schedulesEnabled), slog.Int("schedulesDisabled", len(rulesFile.Schedule)-schedulesEnabled))
+}
+
+// validateActions rejects actions that move further than --max-action-step, and
+// warns about ones that would always be clamped to the end of the ladder.
+func validateActions(args Options, data *RulesFile) error {
+	check := func(what string, action int) error {
+		step := max(action, -action)
+		if args.MaxActionStep > 0 && step > args.MaxActionStep {
+			return fmt.Errorf("%s: action %d is more than %d steps (raise --max-action-step if this is intended)", what, action, args.MaxActionStep)
+		}
+		if len(args.Scaler.AllowedServerSizes) > 0 && step >= len(args.Scaler.AllowedServerSizes) {
+			slog.Warn("action is larger than the ladder and will always clamp to the end of it", slog.String("source", what), slog.Int("action", action), slog.Int("sizes", len(args.Scaler.AllowedServerSizes)))
+		}
+		return nil
+	}
+	for i, rule := range data.Rules {
+		if err := check(fmt.Sprintf("rule %d (%s)", i, rule), rule.Action); err != nil {
+			return err
+		}
+	}
+	for _, sch := range data.Schedule {
+		if err := check("schedule "+sch.DisplayName(), sch.Action); err != nil {
+			return err
+		}
+	}
+	for _, alert := range data.Alerts {
+		if err := check("alert "+alert.Name, alert.Action); err != nil {
+			return err
+		}
+		if err := check("alert "+alert.Name, alert.ResolvedAction); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newNotifier creates a notifier that fans out to every configured channel, or nil if there are none.
+func newNotifier(args Options, logger *slog.Logger) notify.Notifier {
+	var channels notify.Multi
+	add := func(spec string, create func(url string) notify.Notifier) {
+		min := notify.SeverityInfo
+		if prefix, url, ok := strings.Cut(spec, "="); ok {
+			if severity, err := notify.ParseSeverity(prefix); err == nil {
+				min, spec = severity, url
+			}
+		}
+		channels = append(channels, notify.MinSeverity(create(spec), min))
+	}
+	for _, spec := range args.Notify.WebhookURL {
+		add(spec, func(url string) notify.Notifier { return notify.NewWebhook(url) })
+	}
+	for _, spec := range args.Notify.GenericWebhookURL {
+		add(spec, func(url string) notify.Notifier { return notify.NewGenericWebhook(url) })
+	}
+	if args.Notify.Grafana.URL != "" {
+		channels = append(channels, notify.NewGrafana(args.Notify.Grafana.URL, args.Notify.Grafana.Token, args.Notify.Grafana.Tags))
+	}
+	if args.Notify.CloudEvents.URL != "" {
+		channels = append(channels, notify.NewCloudEvents(args.Notify.CloudEvents.URL, args.Notify.CloudEvents.Source))
+	}
+	if args.Notify.Log {
+		channels = append(channels, notify.Log{Logger: logger})
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+	return channels
+}
+
+// newProvider creates the configured cloud provider, returning it along with the name of the server it manages.
+func newProvider(args Options) (autoscaler.Provider, string, error) {
+	switch args.Scaler.Provider {
+	case "azure":
+		scaler, err := azure.NewAutoscaler(args.Scaler.Azure.SubscriptionID, args.Scaler.Azure.ResourceGroup, args.Scaler.Azure.VMName, azure.AzureAutoscalerOptions{
+			SizesCacheLifetime:      args.Scaler.Azure.SizesCacheTime,
+			StopTimeout:             args.Scaler.Azure.StopTimeout,
+			AllowArchitectureChange: args.Scaler.Azure.AllowArchitectureChange,
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create azure autoscaler: %w", err)
+		}
+		return scaler, args.Scaler.Azure.VMName, nil
+	default:
+		scaler, err := hcloud.NewAutoscaler(args.Scaler.Hetzner.APIKey, args.Scaler.Hetzner.ServerName, hcloud.HCloudAutoscalerOptions{
+			ServerTypesCacheLifetime: args.Scaler.Hetzner.ServerTypesCacheTime,
+			RestrictToLocation:       args.Scaler.Hetzner.RestrictToLocation,
+			RestrictToArchitecture:   args.Scaler.Hetzner.RestrictToArchitecture,
+			AllowArchitectureChange:  args.Scaler.Hetzner.AllowArchitectureChange,
+			StopTimeout:              args.Scaler.Hetzner.StopTimeout,
+			StopPollMaxInterval:      args.Scaler.Hetzner.StopPollMaxInterval,
+		})
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to create hcloud autoscaler: %w", err)
+		}
+		return scaler, args.Scaler.Hetzner.ServerName, nil
 	}
-	_, err := toml.DecodeFile(args.RulesFile, &data)
+}
+
+// newController creates the configured Minecraft controller, applying the rules file's server overrides.
+func newController(args Options, rulesFile *RulesFile) autoscaler.MinecraftController {
+	rconAddress, rconPassword := args.Minecraft.RCON.Address, args.Minecraft.RCON.Password
+	if rulesFile.Server.RconAddress != "" {
+		rconAddress = rulesFile.Server.RconAddress
+	}
+	if rulesFile.Server.RconPassword != "" {
+		rconPassword = rulesFile.Server.RconPassword
+	}
+	switch args.Minecraft.Controller {
+	case "http":
+		return autoscaler.NewHTTPPluginController(args.Minecraft.HTTP.URL, args.Minecraft.HTTP.Token)
+	default:
+		return autoscaler.NewRCONController(rconAddress, rconPassword)
+	}
+}
+
+func newMetrics(args Options) (*metrics.PrometheusMCMetrics, error) {
+	promOpts := metrics.PrometheusOptions{
+		Addresses:   args.Metrics.Address,
+		Username:    args.Metrics.Username,
+		Password:    args.Metrics.Password,
+		BearerToken: args.Metrics.BearerToken,
+	}
+	if args.Metrics.BearerTokenFile != "" {
+		readToken := func() (string, error) {
+			token, err := os.ReadFile(args.Metrics.BearerTokenFile)
+			if err != nil {
+				return "", fmt.Errorf("failed to read bearer token file: %w", err)
+			}
+			return strings.TrimSpace(string(token)), nil
+		}
+		var err error
+		promOpts.BearerToken, err = readToken()
+		if err != nil {
+			return nil, err
+		}
+		promOpts.RefreshToken = readToken
+	}
+	mcMetrics, err := metrics.NewPrometheusMCMetricsWithOptions(promOpts)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to load rules file: %w", err)
+		return nil, fmt.Errorf("failed to create prometheus metrics: %w", err)
+	}
+	if args.Metrics.CacheTTL > 0 {
+		mcMetrics.EnableCache(args.Metrics.CacheTTL)
 	}
-	return data.Rules, data.Schedule, nil
+	return mcMetrics, nil
 }
 
 func main() {
 	var args Options
 	kongCtx := kong.Parse(&args)
+	if args.PrintConfig {
+		kongCtx.FatalIfErrorf(printConfig(os.Stdout, args))
+		kongCtx.Exit(0)
+	}
+	if kongCtx.Command() == "version" {
+		printVersion()
+		return
+	}
 
-	logger := slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+	if args.LogFile == "" && !args.LogStderr {
+		kongCtx.Fatalf("--no-log-stderr requires --log-file")
+	}
+	var logOutput []io.Writer
+	if args.LogStderr {
+		logOutput = append(logOutput, os.Stderr)
+	}
+	if args.LogFile != "" {
+		logFile := &lumberjack.Logger{
+			Filename:   args.LogFile,
+			MaxSize:    args.LogMaxSize,
+			MaxBackups: args.LogMaxBackups,
+		}
+		defer logFile.Close()
+		logOutput = append(logOutput, logFile)
+	}
+	logger := slog.New(slog.NewTextHandler(io.MultiWriter(logOutput...), &slog.HandlerOptions{
 		Level: args.LogLevel,
 	}))
+	for k, v := range args.Labels {
+		logger = logger.With(slog.String(k, v))
+	}
 	slog.SetDefault(logger)
+	logger.Debug("resolved options", slog.Any("options", args))
+
+	if args.IdleScaleToCheapest && args.ScaleDownEmptyFor <= 0 {
+		kongCtx.Fatalf("--idle-scale-to-cheapest requires --scale-down-empty-for to say what idle means")
+	}
+	if args.EmptyCheck == autoscaler.EmptyCheckNone && args.ScaleDownEmptyFor > 0 && args.Metrics.PlayerCountQuery == "" {
+		kongCtx.Fatalf("--scale-down-empty-for needs a player count, so requires --metrics.player-count-query with --empty-check=none")
+	}
+	if args.Scaler.OnMetricsUnavailable == autoscaler.MetricsUnavailableScaleToSafe && args.Scaler.SafeSize == "" {
+		kongCtx.Fatalf("--scaler.safe-size is required with --scaler.on-metrics-unavailable=%s", autoscaler.MetricsUnavailableScaleToSafe)
+	}
+	for _, size := range []string{args.Scaler.MinSize, args.Scaler.MaxSize, args.Scaler.SafeSize} {
+		if size != "" && !slices.Contains(args.Scaler.AllowedServerSizes, size) {
+			kongCtx.Fatalf("size %s is not one of the allowed sizes", size)
+		}
+	}
+	for size := range args.Scaler.SizeCapacity {
+		if !slices.Contains(args.Scaler.AllowedServerSizes, size) {
+			kongCtx.Fatalf("--scaler.size-capacity: size %s is not one of the allowed sizes", size)
+		}
+	}
+
+	if kongCtx.Command() == "preflight" {
+		kongCtx.Exit(preflight(args))
+	}
+	if kongCtx.Command() == "cost" {
+		kongCtx.FatalIfErrorf(cost(args, logger))
+		kongCtx.Exit(0)
+	}
+	if kongCtx.Command() == "simulate" {
+		kongCtx.FatalIfErrorf(simulate(args, logger))
+		kongCtx.Exit(0)
+	}
 
-	rules, schedule, err := loadRules(args)
+	rulesFile, err := loadRules(args)
 	if err != nil {
 		kongCtx.FatalIfErrorf(err)
 	}
-	logger.Debug("loaded rules", slog.Any("rules", rules))
+	logger.Debug("loaded rules", slog.Any("rules", rulesFile.Rules))
+	logEnabled(logger, rulesFile)
+
+	cfg := autoscalerConfig(args, rulesFile)
+	if err := cfg.Validate(); err != nil {
+		kongCtx.Fatalf("%s", err)
+	}
 
-	metrics, err := metrics.NewPrometheusMCMetrics(args.Metrics.Address, args.Metrics.Username, args.Metrics.Password)
+	controller := newController(args, rulesFile)
+
+	mcMetrics, err := newMetrics(args)
 	if err != nil {
-		kongCtx.FatalIfErrorf(fmt.Errorf("failed to create prometheus metrics: %w", err))
+		kongCtx.FatalIfErrorf(err)
 	}
 
-	scaler, err := hcloud.NewAutoscaler(args.Scaler.Hetzner.APIKey, args.Scaler.Hetzner.ServerName, hcloud.HCloudAutoscalerOptions{
-		ServerTypesCacheLifetime: args.Scaler.Hetzner.ServerTypesCacheTime,
-	})
+	scaler, serverName, err := newProvider(args)
 	if err != nil {
-		kongCtx.FatalIfErrorf(fmt.Errorf("failed to create hcloud autoscaler: %w", err))
+		kongCtx.FatalIfErrorf(err)
 	}
 
-	a := autoscaler.NewAutoscaler(autoscaler.AutoScalerConfig{
-		Logger:  logger,
-		Metrics: metrics,
-		Scaler:  scaler,
+	selfMetrics, err := metrics.NewSelfMetricsWithLabels(args.Labels)
+	if err != nil {
+		kongCtx.Fatalf("invalid --labels: %s", err)
+	}
 
-		AllowedSizes:          args.Scaler.AllowedServerSizes,
-		Rules:                 rules,
-		Schedule:              schedule,
-		MinTimeBetweenActions: args.MinTimeBetweenScale,
+	notifier := newNotifier(args, logger)
 
-		PreShutdownMessage: args.Scaler.PreShutdownMessage,
+	var statusResponder *mcstatus.Responder
+	if args.Minecraft.Status.Address != "" {
+		statusResponder, err = mcstatus.NewResponder(args.Minecraft.Status.Address, args.Minecraft.Status.MOTD)
+		if err != nil {
+			kongCtx.FatalIfErrorf(err)
+		}
+	}
 
-		RconAddress:  args.Minecraft.RCON.Address,
-		RconPassword: args.Minecraft.RCON.Password,
-	})
+	var confirm func(context.Context, string) bool
+	switch {
+	case args.DryRun:
+		confirm = dryRunConfirm
+	case args.Interactive:
+		confirm = newStdinConfirmer(os.Stdin, args.InteractiveTimeout).Confirm
+	}
+
+	var locker autoscaler.Locker
+	if args.LockFile != "" {
+		locker = autoscaler.NewFileLocker(args.LockFile)
+	}
+
+	cfg.Logger = logger
+	cfg.Metrics = mcMetrics
+	cfg.SelfMetrics = selfMetrics
+	cfg.Scaler = scaler
+	cfg.ServerName = serverName
+	cfg.Confirm = confirm
+	cfg.Locker = locker
+	cfg.Notifier = notifier
+	cfg.Controller = controller
+	cfg.StatusResponder = statusResponder
+	a := autoscaler.NewAutoscaler(cfg)
 
 	ctx := context.Background()
 	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt)
 	defer cancel()
 
-	a.SetupSchedule(ctx)
+	if err := a.CheckSizeBounds(ctx); err != nil {
+		kongCtx.Fatalf("invalid --scaler.min-size or --scaler.max-size: %s", err)
+	}
+
+	if args.HTTP.Address != "" {
+		auth := httpAuth{username: args.HTTP.Username, password: args.HTTP.Password, bearerToken: args.HTTP.BearerToken}
+		serveHTTP(ctx, args.HTTP.Address, requireAuth(auth, newHTTPHandler(ctx, a, selfMetrics)))
+	}
+
+	if err := a.SetupSchedule(ctx); err != nil {
+		kongCtx.Fatalf("failed to set up schedules: %s", err)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			logger.Info("SIGHUP received, reloading rules")
+			newRules, err := loadRules(args)
+			if err != nil {
+				logger.Error("failed to reload rules, keeping the current ones", slog.String("error", err.Error()))
+				continue
+			}
+			logEnabled(logger, newRules)
+			a.Reload(ctx, autoscaler.RuleSet{
+				Rules:       newRules.Rules,
+				Schedule:    newRules.Schedule,
+				TimeWindows: newRules.TimeWindows,
+				PreScales:   newRules.PreScales,
+				Alerts:      newRules.Alerts,
+			})
+		}
+	}()
 
 	logger.Info("core loop starting", slog.Any("interval", args.Interval))
+	// CoreLoop can outlast the interval while a scale is in progress, so run it in the
+	// background and skip iterations rather than letting evaluations overlap.
+	var loopRunning atomic.Bool
+	var loops sync.WaitGroup
+	// Only touched here, so that an iteration stuck past --loop-timeout is reported once.
+	var loopStartedAt time.Time
+	var reportedStuck bool
 	for {
-		logger.Info("core loop iteration")
-		err = a.CoreLoop(ctx)
-		if err != nil {
-			logger.Error("core loop error", slog.String("error", err.Error()))
+		if loopRunning.CompareAndSwap(false, true) {
+			logger.Info("core loop iteration")
+			loopStartedAt, reportedStuck = time.Now(), false
+			loops.Add(1)
+			go func() {
+				defer loops.Done()
+				defer loopRunning.Store(false)
+				loopCtx, cancel := ctx, context.CancelFunc(func() {})
+				if args.LoopTimeout > 0 {
+					loopCtx, cancel = context.WithTimeout(ctx, args.LoopTimeout)
+				}
+				defer cancel()
+				err := a.CoreLoop(loopCtx)
+				if errors.Is(loopCtx.Err(), context.DeadlineExceeded) {
+					logger.Error("core loop timed out, giving up on it", slog.Duration("timeout", args.LoopTimeout), slog.Any("error", err))
+				} else if err != nil {
+					logger.Error("core loop error", slog.String("error", err.Error()))
+				}
+			}()
+		} else if running := time.Since(loopStartedAt); args.LoopTimeout > 0 && running > args.LoopTimeout && !reportedStuck {
+			reportedStuck = true
+			logger.Error("core loop still running past its timeout, something it called is ignoring cancellation", slog.Duration("running", running), slog.Duration("timeout", args.LoopTimeout))
+			if notifier != nil {
+				msg := fmt.Sprintf("The core loop on %s has been running for %s, past its timeout of %s. No scaling will happen until it returns.", serverName, running.Round(time.Second), args.LoopTimeout)
+				if err := notifier.Notify(ctx, notify.SeverityError, msg); err != nil {
+					logger.Warn("failed to send notification", slog.String("error", err.Error()))
+				}
+			}
+		} else {
+			logger.Info("previous loop still running, skipping")
 		}
 		select {
 		case <-ctx.Done():
+			// Don't exit in the middle of a scale, e.g. with the server stopped.
+			if loopRunning.Load() {
+				logger.Info("waiting for the core loop to finish")
+			}
+			loops.Wait()
 			return
 		case <-time.After(args.Interval):
 		}
 	}
 }
+
+// autoscalerConfig returns the autoscaler's settings from the options and rules,
+// without any of the integrations it talks to.
+func autoscalerConfig(args Options, rulesFile *RulesFile) autoscaler.AutoScalerConfig {
+	return autoscaler.AutoScalerConfig{
+		AllowedSizes:         args.Scaler.AllowedServerSizes,
+		OnUnknownCurrentSize: args.Scaler.OnUnknownSize,
+		MinSize:              args.Scaler.MinSize,
+		SizeCapacity:         args.Scaler.SizeCapacity,
+
+		OnMetricsUnavailable:     args.Scaler.OnMetricsUnavailable,
+		MetricsUnavailableAfter:  args.Scaler.MetricsUnavailableAfter,
+		SafeSize:                 args.Scaler.SafeSize,
+		ProviderUnavailableAfter: args.Scaler.ProviderUnavailableAfter,
+		MaxSize:                  args.Scaler.MaxSize,
+		MonthlyBudget:            args.Scaler.MonthlyBudget,
+		Rules:                    rulesFile.Rules,
+		Schedule:                 rulesFile.Schedule,
+		TimeWindows:              rulesFile.TimeWindows,
+		PreScales:                rulesFile.PreScales,
+		Alerts:                   rulesFile.Alerts,
+		MinTimeBetweenActions:    args.MinTimeBetweenScale,
+		MinTimeBetweenScaleUp:    args.MinTimeBetweenScaleUp,
+		MinTimeBetweenScaleDown:  args.MinTimeBetweenScaleDown,
+		PostScaleUpHold:          args.PostScaleUpHold,
+		MetricsWarmup:            args.MetricsWarmup,
+		RecentEventsSize:         args.RecentEvents,
+
+		LockTimeout: args.LockTimeout,
+
+		CircuitBreakerThreshold: args.CircuitBreaker.Threshold,
+		CircuitBreakerCooldown:  args.CircuitBreaker.Cooldown,
+
+		CalendarURL:            args.Calendar.URL,
+		CalendarLead:           args.Calendar.Lead,
+		CalendarRefresh:        args.Calendar.Refresh,
+		PreShutdownMessage:     args.Scaler.PreShutdownMessage,
+		PreShutdownRepeatEvery: args.Scaler.PreShutdownRepeatEvery,
+		NotifyOnReload:         args.Notify.Reloads,
+		NotifyClampedEvery:     args.Notify.ClampedEvery,
+
+		StopCommand:     args.Minecraft.StopCommand,
+		SkipStopCommand: args.Minecraft.SkipStopCommand,
+		ReadyCommand:    args.Minecraft.ReadyCommand,
+		ReadyTimeout:    args.Minecraft.ReadyTimeout,
+
+		PlayerCountQuery:  args.Metrics.PlayerCountQuery,
+		MaintenanceQuery:  args.Metrics.MaintenanceQuery,
+		PingAddress:       args.Minecraft.PingAddress,
+		RconMetricCommand: args.Minecraft.Metric.Command,
+		RconMetricRegex:   args.Minecraft.Metric.Regex,
+		RconMetricName:    args.Minecraft.Metric.Name,
+		ScaleDownEmptyFor: args.ScaleDownEmptyFor,
+
+		IdleScaleToCheapest: args.IdleScaleToCheapest,
+		ScaleDownStepSettle: args.ScaleDownStepSettle,
+
+		EmptyCheck:            args.EmptyCheck,
+		PreShutdownDelay:      args.PreShutdownDelay,
+		EmptyWaitPollInterval: args.EmptyWaitPoll,
+		EmptyConfirmations:    args.EmptyConfirmations,
+
+		DrainCommand:      args.Minecraft.Drain.Command,
+		DrainRconAddress:  args.Minecraft.Drain.RCON.Address,
+		DrainRconPassword: args.Minecraft.Drain.RCON.Password,
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr bool
+	}{
+		{
+			name: "valid pre-scale",
+			rules: `[[pre_scale]]
+name = "raid"
+cron = "0 20 * * 5"
+lead = "30m"
+duration = "2h"
+target = "cax41"`,
+		},
+		{
+			name: "pre-scale with invalid cron",
+			rules: `[[pre_scale]]
+name = "raid"
+cron = "every friday"
+target = "cax41"`,
+			wantErr: true,
+		},
+		{
+			name: "pre-scale to a size that isn't allowed",
+			rules: `[[pre_scale]]
+name = "raid"
+cron = "0 20 * * 5"
+target = "cax51"`,
+			wantErr: true,
+		},
+		{
+			name: "valid time window",
+			rules: `[[time_window]]
+name = "weekend"
+start = "10:00"
+end = "02:00"
+days = ["sat", "Sunday"]
+size = "cax31"`,
+		},
+		{
+			name: "time window to a size that isn't allowed",
+			rules: `[[time_window]]
+name = "weekend"
+start = "10:00"
+end = "02:00"
+size = "cx31"`,
+			wantErr: true,
+		},
+		{
+			name: "schedule with invalid cron",
+			rules: `[[schedule]]
+name = "evening"
+cron = "0 25 * * *"
+action = 1`,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.toml")
+			if err := os.WriteFile(path, []byte("version = 1\n"+tt.rules), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			var args Options
+			args.RulesFile = path
+			args.Scaler.AllowedServerSizes = []string{"cax11", "cax21", "cax31", "cax41"}
+			if _, err := loadRules(args); (err != nil) != tt.wantErr {
+				t.Errorf("loadRules() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package mcstatus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Tnze/go-mc/bot"
+)
+
+// pingTimeout bounds a single server list ping.
+const pingTimeout = 10 * time.Second
+
+// Players is the player section of a server list ping response.
+type Players struct {
+	Online int `json:"online"`
+	Max    int `json:"max"`
+}
+
+// Ping asks the server at address for its status with a server list ping, as the
+// multiplayer menu does, and returns its player counts. Unlike RCON, this needs no password.
+func Ping(ctx context.Context, address string) (Players, error) {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+	defer cancel()
+	data, _, err := bot.PingAndListContext(ctx, address)
+	if err != nil {
+		return Players{}, fmt.Errorf("failed to ping %s: %w", address, err)
+	}
+	var status struct {
+		Players Players `json:"players"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return Players{}, fmt.Errorf("failed to parse status from %s: %w", address, err)
+	}
+	return status.Players, nil
+}
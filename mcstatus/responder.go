@@ -0,0 +1,159 @@
+package mcstatus
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Tnze/go-mc/chat"
+	mcnet "github.com/Tnze/go-mc/net"
+	pk "github.com/Tnze/go-mc/net/packet"
+)
+
+// Responder answers Minecraft server list pings with a fixed MOTD, and
+// disconnects players trying to join with the same message. It is meant to
+// stand in for the server on its port while the server is down.
+type Responder struct {
+	address string
+	motd    json.RawMessage
+
+	listener *mcnet.Listener
+	mux      sync.Mutex
+}
+
+// NewResponder creates a responder for the given address. The MOTD is either
+// plain text or a JSON text component.
+func NewResponder(address, motd string) (*Responder, error) {
+	var raw json.RawMessage
+	if len(motd) > 0 && motd[0] == '{' {
+		if !json.Valid([]byte(motd)) {
+			return nil, fmt.Errorf("invalid MOTD JSON: %q", motd)
+		}
+		raw = json.RawMessage(motd)
+	} else {
+		var err error
+		raw, err = json.Marshal(chat.Text(motd))
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode MOTD: %w", err)
+		}
+	}
+	return &Responder{address: address, motd: raw}, nil
+}
+
+// Start begins answering pings. It is a no-op if the responder is already running.
+func (r *Responder) Start() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.listener != nil {
+		return nil
+	}
+	l, err := mcnet.ListenMC(r.address)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", r.address, err)
+	}
+	r.listener = l
+	slog.Info("status responder started", slog.String("address", r.address))
+	go r.serve(l)
+	return nil
+}
+
+// Stop stops answering pings and frees the port for the real server.
+func (r *Responder) Stop() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.listener == nil {
+		return nil
+	}
+	err := r.listener.Close()
+	r.listener = nil
+	slog.Info("status responder stopped", slog.String("address", r.address))
+	return err
+}
+
+func (r *Responder) serve(l *mcnet.Listener) {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				slog.Warn("status responder failed to accept", slog.String("error", err.Error()))
+			}
+			return
+		}
+		go func() {
+			defer conn.Close()
+			conn.Socket.SetDeadline(time.Now().Add(10 * time.Second))
+			if err := r.handle(&conn); err != nil {
+				slog.Debug("status responder connection failed", slog.String("error", err.Error()))
+			}
+		}()
+	}
+}
+
+const (
+	intentionStatus = 1
+	intentionLogin  = 2
+)
+
+func (r *Responder) handle(conn *mcnet.Conn) error {
+	var (
+		p                   pk.Packet
+		protocol, intention pk.VarInt
+		serverAddress       pk.String
+		serverPort          pk.UnsignedShort
+	)
+	if err := conn.ReadPacket(&p); err != nil {
+		return fmt.Errorf("failed to read handshake: %w", err)
+	}
+	if err := p.Scan(&protocol, &serverAddress, &serverPort, &intention); err != nil {
+		return fmt.Errorf("failed to parse handshake: %w", err)
+	}
+	switch intention {
+	case intentionStatus:
+		// Status request, then optionally a ping to echo back.
+		for i := 0; i < 2; i++ {
+			if err := conn.ReadPacket(&p); err != nil {
+				return nil
+			}
+			switch p.ID {
+			case 0x00:
+				resp, err := r.statusResponse(int32(protocol))
+				if err != nil {
+					return err
+				}
+				if err := conn.WritePacket(pk.Marshal(0x00, pk.String(resp))); err != nil {
+					return err
+				}
+			case 0x01:
+				return conn.WritePacket(p)
+			}
+		}
+		return nil
+	case intentionLogin:
+		return conn.WritePacket(pk.Marshal(0x00, pk.String(r.motd)))
+	default:
+		return fmt.Errorf("unknown intention %d", intention)
+	}
+}
+
+func (r *Responder) statusResponse(protocol int32) ([]byte, error) {
+	var status struct {
+		Version struct {
+			Name     string `json:"name"`
+			Protocol int32  `json:"protocol"`
+		} `json:"version"`
+		Players struct {
+			Max    int `json:"max"`
+			Online int `json:"online"`
+		} `json:"players"`
+		Description json.RawMessage `json:"description"`
+	}
+	status.Version.Name = "mcas"
+	// Echo the client's protocol so it doesn't show the server as incompatible.
+	status.Version.Protocol = protocol
+	status.Description = r.motd
+	return json.Marshal(status)
+}
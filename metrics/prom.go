@@ -2,9 +2,11 @@ package metrics
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/api"
@@ -13,10 +15,28 @@ import (
 )
 
 type PrometheusMCMetrics struct {
-	address  string
-	username string
-	password string
-	api      v1.API
+	username  string
+	password  string
+	endpoints []*endpoint
+
+	mux sync.Mutex
+
+	cacheTTL time.Duration
+	cache    map[string]cachedResult
+	cacheMux sync.Mutex
+
+	now func() time.Time
+}
+
+type cachedResult struct {
+	val model.Value
+	at  time.Time
+}
+
+type endpoint struct {
+	address string
+	api     v1.API
+	healthy bool
 }
 
 // Create a custom RoundTripper for basic auth
@@ -31,39 +51,282 @@ func (b *basicAuthRoundTripper) RoundTrip(req *http.Request) (*http.Response, er
 	return b.rt.RoundTrip(req)
 }
 
-func NewPrometheusMCMetrics(address string, username, password string) (*PrometheusMCMetrics, error) {
-	cfg := api.Config{
-		Address: address,
+// bearerTokenRoundTripper authenticates requests with a bearer token, refreshing
+// the token and retrying once if the server rejects it.
+type bearerTokenRoundTripper struct {
+	token   string
+	refresh func() (string, error)
+	rt      http.RoundTripper
+
+	mux sync.Mutex
+}
+
+func (b *bearerTokenRoundTripper) currentToken() string {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.token
+}
+
+func (b *bearerTokenRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	token := b.currentToken()
+	r := req.Clone(req.Context())
+	r.Header.Set("Authorization", "Bearer "+token)
+	resp, err := b.rt.RoundTrip(r)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || b.refresh == nil {
+		return resp, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// Can't replay the request body, so let the caller see the 401.
+		return resp, nil
+	}
+	slog.Info("prometheus rejected bearer token, refreshing")
+	newToken, err := b.refresh()
+	if err != nil {
+		return resp, nil
+	}
+	resp.Body.Close()
+	b.mux.Lock()
+	b.token = newToken
+	b.mux.Unlock()
+	r = req.Clone(req.Context())
+	if req.GetBody != nil {
+		r.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
 	}
+	r.Header.Set("Authorization", "Bearer "+newToken)
+	return b.rt.RoundTrip(r)
+}
+
+type PrometheusOptions struct {
+	// Queried in order, preferring ones that have recently succeeded, until one succeeds.
+	Addresses []string
+
+	Username string
+	Password string
+
+	BearerToken string
+	// If set, called to get a new bearer token when Prometheus responds 401 Unauthorized.
+	RefreshToken func() (string, error)
+}
 
-	if username != "" && password != "" {
-		cfg.RoundTripper = &basicAuthRoundTripper{
-			username: username,
-			password: password,
+func NewPrometheusMCMetrics(address string, username, password string) (*PrometheusMCMetrics, error) {
+	return NewPrometheusMCMetricsWithOptions(PrometheusOptions{
+		Addresses: []string{address},
+		Username:  username,
+		Password:  password,
+	})
+}
+
+func NewPrometheusMCMetricsWithOptions(opts PrometheusOptions) (*PrometheusMCMetrics, error) {
+	if len(opts.Addresses) == 0 {
+		return nil, fmt.Errorf("no prometheus addresses given")
+	}
+	p := &PrometheusMCMetrics{
+		username: opts.Username,
+		password: opts.Password,
+	}
+	var rt http.RoundTripper
+	switch {
+	case opts.BearerToken != "" || opts.RefreshToken != nil:
+		rt = &bearerTokenRoundTripper{
+			token:   opts.BearerToken,
+			refresh: opts.RefreshToken,
+			rt:      api.DefaultRoundTripper,
+		}
+	case opts.Username != "" && opts.Password != "":
+		rt = &basicAuthRoundTripper{
+			username: opts.Username,
+			password: opts.Password,
 			rt:       api.DefaultRoundTripper,
 		}
 	}
+	for _, address := range opts.Addresses {
+		client, err := api.NewClient(api.Config{
+			Address:      address,
+			RoundTripper: rt,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to create prometheus client for %s: %w", address, err)
+		}
 
-	client, err := api.NewClient(cfg)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create prometheus client: %w", err)
+		p.endpoints = append(p.endpoints, &endpoint{
+			address: address,
+			api:     v1.NewAPI(client),
+			healthy: true,
+		})
 	}
+	return p, nil
+}
 
-	v1api := v1.NewAPI(client)
+// orderedEndpoints returns the healthy endpoints followed by the unhealthy ones.
+func (p *PrometheusMCMetrics) orderedEndpoints() []*endpoint {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	rv := make([]*endpoint, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		if e.healthy {
+			rv = append(rv, e)
+		}
+	}
+	for _, e := range p.endpoints {
+		if !e.healthy {
+			rv = append(rv, e)
+		}
+	}
+	return rv
+}
 
-	return &PrometheusMCMetrics{
-		address:  address,
-		username: username,
-		password: password,
-		api:      v1api,
-	}, nil
+func (p *PrometheusMCMetrics) setHealthy(e *endpoint, healthy bool) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if e.healthy != healthy {
+		slog.Info("prometheus endpoint health changed", slog.String("address", e.address), slog.Bool("healthy", healthy))
+	}
+	e.healthy = healthy
+}
+
+// withFailover calls fn against each endpoint in turn until one succeeds.
+func (p *PrometheusMCMetrics) withFailover(ctx context.Context, fn func(v1.API) error) error {
+	var err error
+	for _, e := range p.orderedEndpoints() {
+		err = fn(e.api)
+		if err == nil {
+			p.setHealthy(e, true)
+			return nil
+		}
+		// A bad query will fail the same way everywhere, so don't blame the endpoint.
+		var apiErr *v1.Error
+		if errors.As(err, &apiErr) && apiErr.Type == v1.ErrBadData {
+			return err
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		slog.WarnContext(ctx, "prometheus query failed", slog.String("address", e.address), slog.String("error", err.Error()))
+		p.setHealthy(e, false)
+	}
+	return err
+}
+
+// SetNow makes instant queries evaluate at the time returned by now rather than
+// the current time, e.g. to replay history.
+func (p *PrometheusMCMetrics) SetNow(now func() time.Time) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.now = now
+}
+
+func (p *PrometheusMCMetrics) evaluationTime() time.Time {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// EnableCache makes Query return the previous result of an identical query if it is younger than ttl.
+func (p *PrometheusMCMetrics) EnableCache(ttl time.Duration) {
+	p.cacheMux.Lock()
+	defer p.cacheMux.Unlock()
+	p.cacheTTL = ttl
+	p.cache = make(map[string]cachedResult)
+}
+
+// InvalidateCache discards all cached query results.
+func (p *PrometheusMCMetrics) InvalidateCache() {
+	p.cacheMux.Lock()
+	defer p.cacheMux.Unlock()
+	if p.cache != nil {
+		clear(p.cache)
+	}
 }
 
 func (p *PrometheusMCMetrics) Query(ctx context.Context, query string) (model.Value, error) {
+	p.cacheMux.Lock()
+	if cached, ok := p.cache[query]; ok && time.Since(cached.at) < p.cacheTTL {
+		p.cacheMux.Unlock()
+		slog.DebugContext(ctx, "using cached prometheus result", slog.String("query", query))
+		return cached.val, nil
+	}
+	p.cacheMux.Unlock()
+	val, err := p.query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	p.cacheMux.Lock()
+	if p.cache != nil {
+		p.cache[query] = cachedResult{val: val, at: time.Now()}
+	}
+	p.cacheMux.Unlock()
+	return val, nil
+}
+
+func (p *PrometheusMCMetrics) query(ctx context.Context, query string) (model.Value, error) {
 	slog.DebugContext(ctx, "querying prometheus", slog.String("query", query))
-	val, _, err := p.api.Query(ctx, query, time.Now())
+	ts := p.evaluationTime()
+	var val model.Value
+	err := p.withFailover(ctx, func(api v1.API) error {
+		var err error
+		val, _, err = api.Query(ctx, query, ts)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to query prometheus: %w", err)
 	}
 	return val, nil
 }
+
+var ErrNoData = errors.New("query returned no data")
+
+// QueryScalar returns the numeric value of a query: the value of a scalar
+// result, or the first sample of a vector result.
+func (p *PrometheusMCMetrics) QueryScalar(ctx context.Context, query string) (float64, error) {
+	val, err := p.Query(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	switch v := val.(type) {
+	case *model.Scalar:
+		return float64(v.Value), nil
+	case model.Vector:
+		if len(v) == 0 {
+			return 0, fmt.Errorf("%w: %q", ErrNoData, query)
+		}
+		return float64(v[0].Value), nil
+	default:
+		return 0, fmt.Errorf("expected scalar or vector result, got %T", val)
+	}
+}
+
+// defaultRangeStep is the resolution used for range queries if none is given.
+const defaultRangeStep = 30 * time.Second
+
+// QueryRange evaluates query over [start, end] at the given step (default 30s),
+// returning the result and the step actually used.
+func (p *PrometheusMCMetrics) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, time.Duration, error) {
+	if step <= 0 {
+		step = defaultRangeStep
+	}
+	slog.DebugContext(ctx, "querying prometheus range", slog.String("query", query), slog.Time("start", start), slog.Time("end", end), slog.Duration("step", step))
+	var val model.Value
+	err := p.withFailover(ctx, func(api v1.API) error {
+		var err error
+		val, _, err = api.QueryRange(ctx, query, v1.Range{
+			Start: start,
+			End:   end,
+			Step:  step,
+		})
+		return err
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to query prometheus: %w", err)
+	}
+	m, ok := val.(model.Matrix)
+	if !ok {
+		return nil, 0, fmt.Errorf("expected matrix result, got %T", val)
+	}
+	return m, step, nil
+}
@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+)
+
+// SelfMetrics holds the metrics that mcas exports about itself.
+type SelfMetrics struct {
+	registry *prometheus.Registry
+
+	ScaleActions          *prometheus.CounterVec
+	SecondsSinceLastScale prometheus.Gauge
+	CooldownRemaining     prometheus.Gauge
+	CircuitOpen           prometheus.Gauge
+	CurrentHourlyPrice    prometheus.Gauge
+	RconMetric            *prometheus.GaugeVec
+	RuleMet               *prometheus.GaugeVec
+	RuleEvalErrors        *prometheus.CounterVec
+}
+
+func NewSelfMetrics() *SelfMetrics {
+	m := newSelfMetrics()
+	m.registry.MustRegister(m.collectors()...)
+	return m
+}
+
+// NewSelfMetricsWithLabels is like NewSelfMetrics, but adds the given constant
+// labels to every metric. It fails if a label name is invalid or clashes with
+// one of the metrics' own labels.
+func NewSelfMetricsWithLabels(labels map[string]string) (*SelfMetrics, error) {
+	for k := range labels {
+		if !model.LabelName(k).IsValid() {
+			return nil, fmt.Errorf("invalid label name %q", k)
+		}
+	}
+	m := newSelfMetrics()
+	registerer := prometheus.WrapRegistererWith(labels, m.registry)
+	for _, c := range m.collectors() {
+		if err := registerer.Register(c); err != nil {
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+func newSelfMetrics() *SelfMetrics {
+	return &SelfMetrics{
+		registry: prometheus.NewRegistry(),
+		ScaleActions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mcas_scale_actions_total",
+			Help: "Number of scaling actions attempted, by direction and outcome.",
+		}, []string{"direction", "outcome"}),
+		SecondsSinceLastScale: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mcas_seconds_since_last_scale",
+			Help: "Seconds since the server was last scaled, or since mcas started if it has not scaled yet.",
+		}),
+		CooldownRemaining: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mcas_cooldown_remaining_seconds",
+			Help: "Seconds until the minimum time between scaling actions has elapsed.",
+		}),
+		CircuitOpen: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mcas_circuit_open",
+			Help: "1 if scaling is suspended because of repeated failures, 0 otherwise.",
+		}),
+		CurrentHourlyPrice: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "mcas_current_hourly_price",
+			Help: "Hourly price of the server's current size, in the provider's currency.",
+		}),
+		RconMetric: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "mcas_rcon_metric",
+			Help: "Latest value parsed from the output of the configured RCON metric command.",
+		}, []string{"name"}),
+		RuleMet: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "mcas_rule_met",
+			Help: "1 if the rule was met when last evaluated, 0 otherwise, by rule name or index.",
+		}, []string{"rule"}),
+		RuleEvalErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "mcas_rule_eval_errors_total",
+			Help: "Number of times evaluating the rule failed, by rule name or index.",
+		}, []string{"rule"}),
+	}
+}
+
+func (m *SelfMetrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.ScaleActions, m.SecondsSinceLastScale, m.CooldownRemaining, m.CircuitOpen, m.CurrentHourlyPrice, m.RconMetric, m.RuleMet, m.RuleEvalErrors}
+}
+
+func (m *SelfMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,25 @@
+package metrics
+
+import "testing"
+
+func TestNewSelfMetricsWithLabels(t *testing.T) {
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{name: "none"},
+		{name: "valid", labels: map[string]string{"env": "prod", "region_1": "eu"}},
+		{name: "hyphenated name", labels: map[string]string{"my-env": "prod"}, wantErr: true},
+		{name: "leading digit", labels: map[string]string{"1env": "prod"}, wantErr: true},
+		{name: "empty name", labels: map[string]string{"": "prod"}, wantErr: true},
+		{name: "clashes with a metric's label", labels: map[string]string{"rule": "x"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewSelfMetricsWithLabels(tt.labels); (err != nil) != tt.wantErr {
+				t.Errorf("NewSelfMetricsWithLabels(%v) error = %v, wantErr %v", tt.labels, err, tt.wantErr)
+			}
+		})
+	}
+}
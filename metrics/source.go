@@ -0,0 +1,21 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// Source is where the autoscaler's rules get their metrics from. PrometheusMCMetrics is
+// the only real implementation.
+type Source interface {
+	Query(ctx context.Context, query string) (model.Value, error)
+	// QueryScalar returns the numeric value of a query, or ErrNoData if it returned nothing.
+	QueryScalar(ctx context.Context, query string) (float64, error)
+	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (model.Matrix, time.Duration, error)
+	// InvalidateCache drops cached results, so the next queries see fresh data.
+	InvalidateCache()
+	// SetNow sets where queries get their evaluation time from, or the current time if now is nil.
+	SetNow(now func() time.Time)
+}
@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityError
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityInfo:
+		return "info"
+	case SeverityWarning:
+		return "warning"
+	case SeverityError:
+		return "error"
+	}
+	return fmt.Sprintf("severity(%d)", int(s))
+}
+
+func ParseSeverity(s string) (Severity, error) {
+	switch strings.ToLower(s) {
+	case "info":
+		return SeverityInfo, nil
+	case "warning", "warn":
+		return SeverityWarning, nil
+	case "error":
+		return SeverityError, nil
+	}
+	return 0, fmt.Errorf("notify: unknown severity %q", s)
+}
+
+// Notifier sends messages about what mcas is doing to somewhere people will see them.
+type Notifier interface {
+	Notify(ctx context.Context, severity Severity, message string) error
+}
+
+// ScaleEvent describes a scaling action that was attempted.
+type ScaleEvent struct {
+	Time    time.Time `json:"time"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Trigger string    `json:"trigger"`
+	Outcome string    `json:"outcome"`
+	Error   string    `json:"error,omitempty"`
+}
+
+func (e ScaleEvent) severity() Severity {
+	if e.Outcome == "error" {
+		return SeverityError
+	}
+	return SeverityInfo
+}
+
+// ScaleNotifier is implemented by notifiers that can also send every scaling action
+// as structured data, for other systems to consume rather than people.
+type ScaleNotifier interface {
+	NotifyScale(ctx context.Context, event ScaleEvent) error
+}
+
+type filtered struct {
+	next Notifier
+	min  Severity
+}
+
+// MinSeverity drops messages less severe than min before passing them to next.
+func MinSeverity(next Notifier, min Severity) Notifier {
+	return &filtered{next: next, min: min}
+}
+
+func (f *filtered) Notify(ctx context.Context, severity Severity, message string) error {
+	if severity < f.min {
+		return nil
+	}
+	return f.next.Notify(ctx, severity, message)
+}
+
+func (f *filtered) NotifyScale(ctx context.Context, event ScaleEvent) error {
+	next, ok := f.next.(ScaleNotifier)
+	if !ok || event.severity() < f.min {
+		return nil
+	}
+	return next.NotifyScale(ctx, event)
+}
+
+// Multi sends each message to all of its notifiers.
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, severity Severity, message string) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, severity, message); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// NotifyScale sends the event to those of its notifiers that are ScaleNotifiers.
+func (m Multi) NotifyScale(ctx context.Context, event ScaleEvent) error {
+	var errs []error
+	for _, n := range m {
+		if sn, ok := n.(ScaleNotifier); ok {
+			if err := sn.NotifyScale(ctx, event); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Log writes messages to a logger, at a level matching their severity.
+type Log struct {
+	Logger *slog.Logger
+}
+
+func (l Log) Notify(ctx context.Context, severity Severity, message string) error {
+	level := slog.LevelInfo
+	switch severity {
+	case SeverityWarning:
+		level = slog.LevelWarn
+	case SeverityError:
+		level = slog.LevelError
+	}
+	l.Logger.Log(ctx, level, "notification", slog.String("message", message))
+	return nil
+}
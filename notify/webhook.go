@@ -0,0 +1,162 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Webhook posts messages to a chat webhook. The payload is understood by both
+// Discord ("content") and Slack ("text") incoming webhooks.
+type Webhook struct {
+	url         string
+	token       string
+	contentType string
+	client      *http.Client
+}
+
+func NewWebhook(url string) *Webhook {
+	return &Webhook{
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (w *Webhook) Notify(ctx context.Context, severity Severity, message string) error {
+	return w.post(ctx, map[string]string{
+		"content": message,
+		"text":    message,
+	})
+}
+
+func (w *Webhook) post(ctx context.Context, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("notify: failed to encode payload: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("notify: failed to create request: %w", err)
+	}
+	contentType := w.contentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	if w.token != "" {
+		req.Header.Set("Authorization", "Bearer "+w.token)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify: failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("notify: webhook returned %s", resp.Status)
+	}
+	return nil
+}
+
+// GenericWebhook posts each message as JSON with its severity, for consumption by other tools.
+type GenericWebhook struct {
+	Webhook
+}
+
+func NewGenericWebhook(url string) *GenericWebhook {
+	return &GenericWebhook{*NewWebhook(url)}
+}
+
+func (w *GenericWebhook) Notify(ctx context.Context, severity Severity, message string) error {
+	return w.post(ctx, map[string]any{
+		"severity": severity.String(),
+		"message":  message,
+		"time":     time.Now(),
+	})
+}
+
+// Grafana records each message as a Grafana annotation, so that scaling events
+// show up on dashboards.
+type Grafana struct {
+	Webhook
+	tags []string
+}
+
+// NewGrafana creates a Grafana notifier for the Grafana instance at baseURL,
+// authenticating with a service account token.
+func NewGrafana(baseURL, token string, tags []string) *Grafana {
+	w := NewWebhook(strings.TrimSuffix(baseURL, "/") + "/api/annotations")
+	w.token = token
+	return &Grafana{Webhook: *w, tags: tags}
+}
+
+func (g *Grafana) Notify(ctx context.Context, severity Severity, message string) error {
+	return g.post(ctx, map[string]any{
+		"time": time.Now().UnixMilli(),
+		"tags": append(slices.Clone(g.tags), severity.String()),
+		"text": message,
+	})
+}
+
+// CloudEvents posts each scaling action, and each message, to an HTTP sink as a
+// CloudEvent in structured mode.
+type CloudEvents struct {
+	Webhook
+	source string
+}
+
+const (
+	CloudEventTypeScale        = "com.mcas.scale"
+	CloudEventTypeNotification = "com.mcas.notification"
+)
+
+// NewCloudEvents creates a CloudEvents notifier that posts to url, with source
+// identifying this mcas instance.
+func NewCloudEvents(url, source string) *CloudEvents {
+	w := NewWebhook(url)
+	w.contentType = "application/cloudevents+json"
+	return &CloudEvents{Webhook: *w, source: source}
+}
+
+type cloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            any       `json:"data"`
+}
+
+func (c *CloudEvents) send(ctx context.Context, eventType string, at time.Time, data any) error {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("notify: failed to generate event ID: %w", err)
+	}
+	return c.post(ctx, cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              hex.EncodeToString(id),
+		Source:          c.source,
+		Type:            eventType,
+		Time:            at,
+		DataContentType: "application/json",
+		Data:            data,
+	})
+}
+
+func (c *CloudEvents) Notify(ctx context.Context, severity Severity, message string) error {
+	return c.send(ctx, CloudEventTypeNotification, time.Now(), map[string]string{
+		"severity": severity.String(),
+		"message":  message,
+	})
+}
+
+func (c *CloudEvents) NotifyScale(ctx context.Context, event ScaleEvent) error {
+	return c.send(ctx, CloudEventTypeScale, event.Time, event)
+}
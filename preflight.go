@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markspolakovs/mcas/autoscaler"
+	"github.com/markspolakovs/mcas/mcstatus"
+)
+
+type preflightCheck struct {
+	name string
+	run  func(ctx context.Context) (string, error)
+}
+
+// preflight checks each integration in turn, prints the results, and returns the exit code.
+func preflight(args Options) int {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	var rulesFile *RulesFile
+	var provider autoscaler.Provider
+	checks := []preflightCheck{
+		{"rules file", func(ctx context.Context) (string, error) {
+			var err error
+			rulesFile, err = loadRules(args)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d rules, %d schedules, %d time windows, %d pre-scales", len(rulesFile.Rules), len(rulesFile.Schedule), len(rulesFile.TimeWindows), len(rulesFile.PreScales)), nil
+		}},
+		{"provider", func(ctx context.Context) (string, error) {
+			var err error
+			var serverName string
+			provider, serverName, err = newProvider(args)
+			if err != nil {
+				return "", err
+			}
+			size, err := provider.GetCurrentSize(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%s is %s", serverName, size), nil
+		}},
+		{"allowed sizes", func(ctx context.Context) (string, error) {
+			if provider == nil {
+				return "", fmt.Errorf("skipped, provider unavailable")
+			}
+			available, err := provider.GetAvailableSizes(ctx)
+			if err != nil {
+				return "", err
+			}
+			var missing []string
+			for _, size := range args.Scaler.AllowedServerSizes {
+				if !slices.Contains(available, size) {
+					missing = append(missing, size)
+				}
+			}
+			if len(missing) > 0 {
+				return "", fmt.Errorf("not available: %s", strings.Join(missing, ", "))
+			}
+			return fmt.Sprintf("%d of %d available sizes allowed", len(args.Scaler.AllowedServerSizes), len(available)), nil
+		}},
+		{"prometheus", func(ctx context.Context) (string, error) {
+			mcMetrics, err := newMetrics(args)
+			if err != nil {
+				return "", err
+			}
+			if _, err := mcMetrics.QueryScalar(ctx, "vector(1)"); err != nil {
+				return "", err
+			}
+			return "sample query succeeded", nil
+		}},
+		{"minecraft", func(ctx context.Context) (string, error) {
+			if rulesFile == nil {
+				rulesFile = &RulesFile{}
+			}
+			controller := newController(args, rulesFile)
+			defer controller.Close()
+			count, err := controller.PlayerCount(ctx)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d players online", count), nil
+		}},
+	}
+	if args.Calendar.URL != "" {
+		checks = append(checks, preflightCheck{"calendar", func(ctx context.Context) (string, error) {
+			events, err := autoscaler.FetchCalendar(ctx, args.Calendar.URL)
+			if err != nil {
+				return "", err
+			}
+			var unknown []string
+			for _, e := range events {
+				if !slices.Contains(args.Scaler.AllowedServerSizes, e.Size) {
+					unknown = append(unknown, e.Size)
+				}
+			}
+			if len(unknown) > 0 {
+				return "", fmt.Errorf("events name sizes that aren't allowed: %s", strings.Join(unknown, ", "))
+			}
+			return fmt.Sprintf("%d events with a size", len(events)), nil
+		}})
+	}
+	if args.Minecraft.PingAddress != "" {
+		checks = append(checks, preflightCheck{"ping", func(ctx context.Context) (string, error) {
+			players, err := mcstatus.Ping(ctx, args.Minecraft.PingAddress)
+			if err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%d of %d players online", players.Online, players.Max), nil
+		}})
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tRESULT\tDETAIL")
+	failed := false
+	for _, c := range checks {
+		detail, err := c.run(ctx)
+		result := "PASS"
+		if err != nil {
+			result = "FAIL"
+			detail = err.Error()
+			failed = true
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", c.name, result, detail)
+	}
+	w.Flush()
+	if failed {
+		return 1
+	}
+	return 0
+}
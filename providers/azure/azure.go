@@ -0,0 +1,428 @@
+package azure
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v6"
+)
+
+var retailPricesURL = "https://prices.azure.com/api/retail/prices"
+
+type AzureAutoscaler struct {
+	resourceGroup string
+	vmName        string
+	vms           *armcompute.VirtualMachinesClient
+	skus          *armcompute.ResourceSKUsClient
+	vm            *armcompute.VirtualMachine
+	opts          AzureAutoscalerOptions
+
+	sizesCache []vmSize
+	sizesAge   time.Time
+
+	mux sync.Mutex
+}
+
+type AzureAutoscalerOptions struct {
+	SizesCacheLifetime time.Duration
+	// How long to wait for the VM to be deallocated before giving up.
+	StopTimeout time.Duration
+	// Allow resizing to a size of a different CPU architecture. Otherwise such
+	// resizes are refused, whatever sizes are available.
+	AllowArchitectureChange bool
+}
+
+type vmSize struct {
+	name         string
+	architecture string
+	hourlyPrice  float64
+}
+
+// NewAutoscaler creates an AzureAutoscaler for the given VM, authenticating
+// with the default Azure credential chain (environment, managed identity, CLI).
+func NewAutoscaler(subscriptionID, resourceGroup, vmName string, opts AzureAutoscalerOptions) (*AzureAutoscaler, error) {
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("azure: failed to create credential: %w", err)
+	}
+	vms, err := armcompute.NewVirtualMachinesClient(subscriptionID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("azure: failed to create VM client: %w", err)
+	}
+	skus, err := armcompute.NewResourceSKUsClient(subscriptionID, cred, nil)
+	if err != nil {
+		return nil, fmt.Errorf("azure: failed to create SKU client: %w", err)
+	}
+	a := &AzureAutoscaler{
+		resourceGroup: resourceGroup,
+		vmName:        vmName,
+		vms:           vms,
+		skus:          skus,
+		opts:          opts,
+	}
+	if err := a.refreshVMUNLOCKED(context.Background()); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AzureAutoscaler) refreshVMUNLOCKED(ctx context.Context) error {
+	resp, err := a.vms.Get(ctx, a.resourceGroup, a.vmName, nil)
+	if err != nil {
+		return fmt.Errorf("azure: failed to get VM: %w", err)
+	}
+	a.vm = &resp.VirtualMachine
+	return nil
+}
+
+func (a *AzureAutoscaler) currentSizeUNLOCKED() string {
+	if a.vm.Properties == nil || a.vm.Properties.HardwareProfile == nil || a.vm.Properties.HardwareProfile.VMSize == nil {
+		return ""
+	}
+	return string(*a.vm.Properties.HardwareProfile.VMSize)
+}
+
+// Refresh re-fetches the VM, and the available sizes if their cache has expired.
+func (a *AzureAutoscaler) Refresh(ctx context.Context) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if err := a.refreshVMUNLOCKED(ctx); err != nil {
+		return err
+	}
+	return a.updateSizesUNLOCKED(ctx)
+}
+
+// GetCurrentSize returns the VM's size as of the last Refresh.
+func (a *AzureAutoscaler) GetCurrentSize(ctx context.Context) (string, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	size := a.currentSizeUNLOCKED()
+	if size == "" {
+		return "", fmt.Errorf("azure: VM has no size")
+	}
+	return size, nil
+}
+
+func (a *AzureAutoscaler) Placement() (architecture, location string) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.architectureUNLOCKED(a.currentSizeUNLOCKED()), *a.vm.Location
+}
+
+// architectureUNLOCKED returns the CPU architecture of the given size, or "" if it isn't known.
+func (a *AzureAutoscaler) architectureUNLOCKED(size string) string {
+	for _, s := range a.sizesCache {
+		if s.name == size {
+			return s.architecture
+		}
+	}
+	return ""
+}
+
+func (a *AzureAutoscaler) updateSizesUNLOCKED(ctx context.Context) error {
+	if a.sizesCache != nil && time.Since(a.sizesAge) < a.opts.SizesCacheLifetime {
+		return nil
+	}
+	location := *a.vm.Location
+	slog.Debug("updating VM sizes cache", slog.String("location", location))
+	prices, err := fetchRetailPrices(ctx, location)
+	if err != nil {
+		return err
+	}
+	var sizes []vmSize
+	pager := a.skus.NewListPager(&armcompute.ResourceSKUsClientListOptions{
+		Filter: to.Ptr(fmt.Sprintf("location eq '%s'", location)),
+	})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("azure: failed to list SKUs: %w", err)
+		}
+		for _, sku := range page.Value {
+			if sku.Name == nil || sku.ResourceType == nil || *sku.ResourceType != "virtualMachines" {
+				continue
+			}
+			if len(sku.Restrictions) > 0 {
+				// Not available to this subscription in this location.
+				continue
+			}
+			price, ok := prices[*sku.Name]
+			if !ok {
+				continue
+			}
+			size := vmSize{name: *sku.Name, hourlyPrice: price}
+			for _, c := range sku.Capabilities {
+				if c.Name != nil && *c.Name == "CpuArchitectureType" && c.Value != nil {
+					size.architecture = *c.Value
+				}
+			}
+			sizes = append(sizes, size)
+		}
+	}
+	slices.SortFunc(sizes, func(a, b vmSize) int {
+		if a.hourlyPrice < b.hourlyPrice {
+			return -1
+		}
+		if a.hourlyPrice > b.hourlyPrice {
+			return 1
+		}
+		return 0
+	})
+	a.sizesCache = sizes
+	a.sizesAge = time.Now()
+	return nil
+}
+
+// fetchRetailPrices returns the cheapest pay-as-you-go Linux hourly price of each VM size in the location.
+func fetchRetailPrices(ctx context.Context, location string) (map[string]float64, error) {
+	filter := fmt.Sprintf("serviceName eq 'Virtual Machines' and armRegionName eq '%s' and priceType eq 'Consumption'", location)
+	next := retailPricesURL + "?$filter=" + url.QueryEscape(filter)
+	prices := make(map[string]float64)
+	for next != "" {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, next, nil)
+		if err != nil {
+			return nil, fmt.Errorf("azure: failed to create prices request: %w", err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("azure: failed to get prices: %w", err)
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			resp.Body.Close()
+			return nil, fmt.Errorf("azure: failed to get prices: %s", resp.Status)
+		}
+		var page struct {
+			Items []struct {
+				ArmSkuName  string  `json:"armSkuName"`
+				RetailPrice float64 `json:"retailPrice"`
+				ProductName string  `json:"productName"`
+				SkuName     string  `json:"skuName"`
+			} `json:"Items"`
+			NextPageLink string `json:"NextPageLink"`
+		}
+		err = json.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("azure: failed to decode prices: %w", err)
+		}
+		for _, item := range page.Items {
+			if item.ArmSkuName == "" || strings.Contains(item.ProductName, "Windows") ||
+				strings.Contains(item.SkuName, "Spot") || strings.Contains(item.SkuName, "Low Priority") {
+				continue
+			}
+			if existing, ok := prices[item.ArmSkuName]; !ok || item.RetailPrice < existing {
+				prices[item.ArmSkuName] = item.RetailPrice
+			}
+		}
+		next = page.NextPageLink
+	}
+	return prices, nil
+}
+
+func (a *AzureAutoscaler) GetAvailableSizes(ctx context.Context) ([]string, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if err := a.updateSizesUNLOCKED(ctx); err != nil {
+		return nil, err
+	}
+	current := a.currentSizeUNLOCKED()
+	var arch string
+	for _, s := range a.sizesCache {
+		if s.name == current {
+			arch = s.architecture
+		}
+	}
+	rv := make([]string, 0, len(a.sizesCache))
+	for _, s := range a.sizesCache {
+		if arch == "" || s.architecture == arch {
+			rv = append(rv, s.name)
+		}
+	}
+	return rv, nil
+}
+
+// GetPrice returns the pay-as-you-go Linux hourly price of the given VM size in the VM's region.
+func (a *AzureAutoscaler) GetPrice(ctx context.Context, size string) (float64, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if err := a.updateSizesUNLOCKED(ctx); err != nil {
+		return 0, err
+	}
+	for _, s := range a.sizesCache {
+		if s.name == size {
+			return s.hourlyPrice, nil
+		}
+	}
+	return 0, fmt.Errorf("azure: no pricing for %s", size)
+}
+
+// powerState returns the VM's power state, e.g. "running" or "deallocated".
+func (a *AzureAutoscaler) powerState(ctx context.Context) (string, error) {
+	resp, err := a.vms.InstanceView(ctx, a.resourceGroup, a.vmName, nil)
+	if err != nil {
+		return "", fmt.Errorf("azure: failed to get instance view: %w", err)
+	}
+	for _, status := range resp.Statuses {
+		if status.Code != nil {
+			if state, ok := strings.CutPrefix(*status.Code, "PowerState/"); ok {
+				return state, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("azure: VM has no power state")
+}
+
+func (a *AzureAutoscaler) StopServer(ctx context.Context) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.deallocateUNLOCKED(ctx)
+}
+
+func (a *AzureAutoscaler) deallocateUNLOCKED(ctx context.Context) error {
+	poller, err := a.vms.BeginDeallocate(ctx, a.resourceGroup, a.vmName, nil)
+	if err != nil {
+		return fmt.Errorf("azure: failed to deallocate VM: %w", err)
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("azure: failed to deallocate VM: %w", err)
+	}
+	slog.Debug("VM deallocation finished, waiting for it to report deallocated")
+	var deadline <-chan time.Time
+	if a.opts.StopTimeout > 0 {
+		deadline = time.After(a.opts.StopTimeout)
+	}
+	for {
+		state, err := a.powerState(ctx)
+		if err != nil {
+			return err
+		}
+		if state == "deallocated" {
+			return nil
+		}
+		slog.Debug("... still waiting ...", slog.String("state", state))
+		select {
+		case <-time.After(5 * time.Second):
+		case <-deadline:
+			return fmt.Errorf("azure: VM did not deallocate within %s", a.opts.StopTimeout)
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// liveResizable reports whether the VM can be resized to size without being
+// deallocated, i.e. whether the size is available on its current hardware cluster.
+func (a *AzureAutoscaler) liveResizable(ctx context.Context, size string) (bool, error) {
+	pager := a.vms.NewListAvailableSizesPager(a.resourceGroup, a.vmName, nil)
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return false, fmt.Errorf("azure: failed to list available sizes: %w", err)
+		}
+		for _, s := range page.Value {
+			if s.Name != nil && *s.Name == size {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// checkArchitectureUNLOCKED refuses a resize to a size of a different or unknown
+// architecture, unless AllowArchitectureChange is set.
+func (a *AzureAutoscaler) checkArchitectureUNLOCKED(size string) error {
+	if a.opts.AllowArchitectureChange {
+		return nil
+	}
+	current := a.currentSizeUNLOCKED()
+	from, to := a.architectureUNLOCKED(current), a.architectureUNLOCKED(size)
+	if from == "" || to == "" {
+		return fmt.Errorf("azure: refusing to resize from %s to %s, as the architecture of one of them is unknown", current, size)
+	}
+	if from != to {
+		return fmt.Errorf("azure: refusing to resize from %s (%s) to %s (%s), which has a different architecture", current, from, size, to)
+	}
+	return nil
+}
+
+func (a *AzureAutoscaler) ResizeServer(ctx context.Context, size string) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	if err := a.checkArchitectureUNLOCKED(size); err != nil {
+		return err
+	}
+	state, err := a.powerState(ctx)
+	if err != nil {
+		return err
+	}
+	if state != "deallocated" {
+		live, err := a.liveResizable(ctx, size)
+		if err != nil {
+			return err
+		}
+		if !live {
+			slog.Info("azure: size not available on the current cluster, deallocating before resize", slog.String("size", size))
+			if err := a.deallocateUNLOCKED(ctx); err != nil {
+				return err
+			}
+			state = "deallocated"
+		}
+	}
+
+	err = a.resizeServerInner(ctx, size)
+	if state == "deallocated" {
+		// Whether or not the resize worked, the server needs to come back up.
+		if startErr := a.startUNLOCKED(ctx); startErr != nil {
+			if err != nil {
+				slog.Warn("azure: server resize failed", slog.String("err", err.Error()))
+			}
+			return startErr
+		}
+	}
+	if err == nil {
+		a.sizesCache = nil
+	}
+	return err
+}
+
+func (a *AzureAutoscaler) resizeServerInner(ctx context.Context, size string) error {
+	poller, err := a.vms.BeginUpdate(ctx, a.resourceGroup, a.vmName, armcompute.VirtualMachineUpdate{
+		Properties: &armcompute.VirtualMachineProperties{
+			HardwareProfile: &armcompute.HardwareProfile{
+				VMSize: to.Ptr(armcompute.VirtualMachineSizeTypes(size)),
+			},
+		},
+	}, nil)
+	if err != nil {
+		return fmt.Errorf("azure: failed to resize VM: %w", err)
+	}
+	resp, err := poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("azure: failed to resize VM: %w", err)
+	}
+	a.vm = &resp.VirtualMachine
+	return nil
+}
+
+func (a *AzureAutoscaler) startUNLOCKED(ctx context.Context) error {
+	poller, err := a.vms.BeginStart(ctx, a.resourceGroup, a.vmName, nil)
+	if err != nil {
+		return fmt.Errorf("azure: failed to start VM: %w", err)
+	}
+	_, err = poller.PollUntilDone(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("azure: failed to start VM: %w", err)
+	}
+	return nil
+}
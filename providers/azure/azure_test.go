@@ -0,0 +1,96 @@
+package azure
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v6"
+)
+
+func TestCheckArchitecture(t *testing.T) {
+	sizes := []vmSize{
+		{name: "Standard_D2s_v5", architecture: "x64"},
+		{name: "Standard_D4s_v5", architecture: "x64"},
+		{name: "Standard_D2ps_v5", architecture: "Arm64"},
+		{name: "Standard_B2s"},
+	}
+	tests := []struct {
+		name    string
+		current string
+		size    string
+		allow   bool
+		wantErr bool
+	}{
+		{name: "same architecture", current: "Standard_D2s_v5", size: "Standard_D4s_v5"},
+		{name: "different architecture", current: "Standard_D2s_v5", size: "Standard_D2ps_v5", wantErr: true},
+		{name: "different architecture allowed", current: "Standard_D2s_v5", size: "Standard_D2ps_v5", allow: true},
+		{name: "target architecture unknown", current: "Standard_D2s_v5", size: "Standard_B2s", wantErr: true},
+		{name: "target not in the sizes", current: "Standard_D2s_v5", size: "Standard_E2s_v5", wantErr: true},
+		{name: "current architecture unknown", current: "Standard_B2s", size: "Standard_D2s_v5", wantErr: true},
+		{name: "unknown allowed", current: "Standard_B2s", size: "Standard_D2s_v5", allow: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &AzureAutoscaler{
+				vm: &armcompute.VirtualMachine{Properties: &armcompute.VirtualMachineProperties{
+					HardwareProfile: &armcompute.HardwareProfile{VMSize: to.Ptr(armcompute.VirtualMachineSizeTypes(tt.current))},
+				}},
+				sizesCache: sizes,
+				opts:       AzureAutoscalerOptions{AllowArchitectureChange: tt.allow},
+			}
+			if err := a.checkArchitectureUNLOCKED(tt.size); (err != nil) != tt.wantErr {
+				t.Errorf("checkArchitectureUNLOCKED(%q) error = %v, wantErr %v", tt.size, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFetchRetailPrices(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{
+			name:   "ok",
+			status: http.StatusOK,
+			body: `{"Items": [
+				{"armSkuName": "Standard_D2s_v5", "retailPrice": 0.096, "productName": "Virtual Machines Dsv5 Series", "skuName": "D2s v5"},
+				{"armSkuName": "Standard_D2s_v5", "retailPrice": 0.019, "productName": "Virtual Machines Dsv5 Series", "skuName": "D2s v5 Spot"},
+				{"armSkuName": "Standard_D2s_v5", "retailPrice": 0.188, "productName": "Virtual Machines Dsv5 Series Windows", "skuName": "D2s v5"}
+			]}`,
+			want: map[string]float64{"Standard_D2s_v5": 0.096},
+		},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: `{"Items": []}`, wantErr: true},
+		{name: "server error", status: http.StatusServiceUnavailable, body: `upstream unavailable`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer srv.Close()
+			defer func(prev string) { retailPricesURL = prev }(retailPricesURL)
+			retailPricesURL = srv.URL
+
+			got, err := fetchRetailPrices(context.Background(), "westeurope")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("fetchRetailPrices() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("fetchRetailPrices() = %v, want %v", got, tt.want)
+			}
+			for size, price := range tt.want {
+				if got[size] != price {
+					t.Errorf("price of %s = %v, want %v", size, got[size], price)
+				}
+			}
+		})
+	}
+}
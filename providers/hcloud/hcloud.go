@@ -27,8 +27,28 @@ type HCloudAutoscaler struct {
 
 type HCloudAutoscalerOptions struct {
 	ServerTypesCacheLifetime time.Duration
+	// Only offer server types available in this location / of this architecture.
+	// Empty means the server's current one, and AnyPlacement disables the restriction.
+	RestrictToLocation     string
+	RestrictToArchitecture string
+	// How long to wait for the server to power off after shutting it down, and
+	// the longest gap between checks as the wait backs off.
+	StopTimeout         time.Duration
+	StopPollMaxInterval time.Duration
+	// Allow resizing to a server type of a different architecture, which the server's
+	// image must be able to boot on. Otherwise such resizes are refused, whatever the
+	// available sizes are restricted to.
+	AllowArchitectureChange bool
 }
 
+const (
+	defaultStopTimeout         = 10 * time.Minute
+	defaultStopPollMaxInterval = 30 * time.Second
+)
+
+// AnyPlacement can be used for RestrictToLocation or RestrictToArchitecture to disable that restriction.
+const AnyPlacement = "*"
+
 func NewAutoscaler(apiKey, serverName string, opts HCloudAutoscalerOptions) (*HCloudAutoscaler, error) {
 	client := hcloud.NewClient(hcloud.WithToken(apiKey))
 	server, _, err := client.Server.GetByName(context.Background(), serverName)
@@ -47,20 +67,49 @@ func NewAutoscaler(apiKey, serverName string, opts HCloudAutoscalerOptions) (*HC
 	}, nil
 }
 
-func (a *HCloudAutoscaler) GetCurrentSize(ctx context.Context) (string, error) {
+// Refresh re-fetches the server, and the server types if their cache has expired.
+func (a *HCloudAutoscaler) Refresh(ctx context.Context) error {
 	a.mux.Lock()
 	defer a.mux.Unlock()
-	var err error
-	a.server, _, err = a.api.Server.GetByID(ctx, a.server.ID)
+	server, _, err := a.api.Server.GetByID(ctx, a.server.ID)
 	if err != nil {
-		return "", fmt.Errorf("hcloud: failed to get server by ID: %w", err)
+		return fmt.Errorf("hcloud: failed to get server by ID: %w", err)
 	}
-	if a.server == nil {
-		return "", fmt.Errorf("hcloud: server not found")
+	if server == nil {
+		return fmt.Errorf("hcloud: server not found")
 	}
+	a.server = server
+	return a.updateServerTypesUNLOCKED(ctx)
+}
+
+// GetCurrentSize returns the server's type as of the last Refresh.
+func (a *HCloudAutoscaler) GetCurrentSize(ctx context.Context) (string, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
 	return a.server.ServerType.Name, nil
 }
 
+// Placement returns the architecture and location that available sizes are restricted to.
+func (a *HCloudAutoscaler) Placement() (architecture, location string) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.architectureUNLOCKED(), a.locationUNLOCKED()
+}
+
+func (a *HCloudAutoscaler) architectureUNLOCKED() string {
+	if a.opts.RestrictToArchitecture != "" {
+		return a.opts.RestrictToArchitecture
+	}
+	return string(a.server.ServerType.Architecture)
+}
+
+func (a *HCloudAutoscaler) locationUNLOCKED() string {
+	if a.opts.RestrictToLocation != "" {
+		return a.opts.RestrictToLocation
+	}
+	return a.server.Datacenter.Location.Name
+}
+
 func (a *HCloudAutoscaler) updateServerTypesUNLOCKED(ctx context.Context) error {
 	if a.serverTypesCache != nil && time.Since(a.serverTypesAge) < a.opts.ServerTypesCacheLifetime {
 		return nil
@@ -78,16 +127,6 @@ func (a *HCloudAutoscaler) updateServerTypesUNLOCKED(ctx context.Context) error
 func (a *HCloudAutoscaler) GetAvailableSizes(ctx context.Context) ([]string, error) {
 	a.mux.Lock()
 	defer a.mux.Unlock()
-	if a.server != nil {
-		var err error
-		a.server, _, err = a.api.Server.GetByID(ctx, a.server.ID)
-		if err != nil {
-			return nil, fmt.Errorf("hcloud: failed to get server by ID: %w", err)
-		}
-		if a.server == nil {
-			return nil, fmt.Errorf("hcloud: server not found")
-		}
-	}
 	err := a.updateServerTypesUNLOCKED(ctx)
 	if err != nil {
 		return nil, err
@@ -109,11 +148,16 @@ func (a *HCloudAutoscaler) GetAvailableSizes(ctx context.Context) ([]string, err
 		}
 		return 0
 	})
+	arch, location := a.architectureUNLOCKED(), a.locationUNLOCKED()
 	rv := make([]string, 0, len(a.serverTypesCache))
 	for _, t := range a.serverTypesCache {
-		if t.Architecture == a.server.ServerType.Architecture {
+		// Disks can't shrink, so types with less disk than the server has are out of reach.
+		if t.Disk < a.server.PrimaryDiskSize {
+			continue
+		}
+		if arch == AnyPlacement || string(t.Architecture) == arch {
 			for _, pricing := range t.Pricings {
-				if pricing.Location.Name == a.server.Datacenter.Location.Name {
+				if location == AnyPlacement || pricing.Location.Name == location {
 					rv = append(rv, t.Name)
 					break
 				}
@@ -123,6 +167,30 @@ func (a *HCloudAutoscaler) GetAvailableSizes(ctx context.Context) ([]string, err
 	return rv, nil
 }
 
+// GetPrice returns the gross hourly price of the given server type in the server's location.
+func (a *HCloudAutoscaler) GetPrice(ctx context.Context, size string) (float64, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	err := a.updateServerTypesUNLOCKED(ctx)
+	if err != nil {
+		return 0, err
+	}
+	t := a.findServerTypeUNLOCKED(size)
+	if t == nil {
+		return 0, fmt.Errorf("hcloud: server type not found: %s", size)
+	}
+	for _, pricing := range t.Pricings {
+		if pricing.Location.Name == a.server.Datacenter.Location.Name {
+			price, err := strconv.ParseFloat(pricing.Hourly.Gross, 64)
+			if err != nil {
+				return 0, fmt.Errorf("hcloud: failed to parse price %q: %w", pricing.Hourly.Gross, err)
+			}
+			return price, nil
+		}
+	}
+	return 0, fmt.Errorf("hcloud: no pricing for %s in %s", size, a.server.Datacenter.Location.Name)
+}
+
 func (a *HCloudAutoscaler) StopServer(ctx context.Context) error {
 	a.mux.Lock()
 	defer a.mux.Unlock()
@@ -138,6 +206,16 @@ func (a *HCloudAutoscaler) StopServer(ctx context.Context) error {
 		return fmt.Errorf("hcloud: failed to shutdown server: %w", err)
 	}
 	slog.Debug("server stopped, waiting for it to actually stop")
+	timeout := a.opts.StopTimeout
+	if timeout <= 0 {
+		timeout = defaultStopTimeout
+	}
+	maxInterval := a.opts.StopPollMaxInterval
+	if maxInterval <= 0 {
+		maxInterval = defaultStopPollMaxInterval
+	}
+	deadline := time.After(timeout)
+	interval := time.Second
 	// stopped doesn't actually mean stopped, sadge. poll until it's really stopped.
 	for {
 		a.server, _, err = a.api.Server.GetByID(ctx, a.server.ID)
@@ -147,52 +225,127 @@ func (a *HCloudAutoscaler) StopServer(ctx context.Context) error {
 		if a.server.Status == hcloud.ServerStatusOff {
 			break
 		}
-		slog.Debug("... still waiting ...", slog.Any("status", a.server.Status))
+		slog.Debug("... still waiting ...", slog.Any("status", a.server.Status), slog.Duration("next", interval))
 		select {
-		case <-time.After(5 * time.Second):
+		case <-time.After(interval):
+		case <-deadline:
+			return fmt.Errorf("hcloud: server did not stop within %s (status %s)", timeout, a.server.Status)
 		case <-ctx.Done():
 			return ctx.Err()
 		}
+		interval = min(interval*2, maxInterval)
 	}
 	return nil
 }
 
 func (a *HCloudAutoscaler) ResizeServer(ctx context.Context, profile string) error {
+	return a.resizeServer(ctx, profile, false)
+}
+
+// ResizeServerUpgradingDisk resizes the server and grows its disk to the new type's.
+// Afterwards, the server can't be resized to types with less disk.
+func (a *HCloudAutoscaler) ResizeServerUpgradingDisk(ctx context.Context, profile string) error {
+	return a.resizeServer(ctx, profile, true)
+}
+
+// DiskSize returns the disk size of the given server type, in GB.
+func (a *HCloudAutoscaler) DiskSize(size string) (int, error) {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	t := a.findServerTypeUNLOCKED(size)
+	if t == nil {
+		return 0, fmt.Errorf("hcloud: server type not found: %s", size)
+	}
+	return t.Disk, nil
+}
+
+// CurrentDiskSize returns the server's primary disk size as of the last Refresh, in GB.
+func (a *HCloudAutoscaler) CurrentDiskSize() int {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	return a.server.PrimaryDiskSize
+}
+
+// PrepareResize checks, while the server is still up, that it can be resized to the
+// given type, so that a resize that is bound to fail doesn't stop the server.
+func (a *HCloudAutoscaler) PrepareResize(ctx context.Context, profile string) error {
 	a.mux.Lock()
 	defer a.mux.Unlock()
+	_, err := a.resizeTargetUNLOCKED(ctx, profile)
+	return err
+}
+
+// resizeTargetUNLOCKED returns the server type to resize to, if the server may be resized to it.
+func (a *HCloudAutoscaler) resizeTargetUNLOCKED(ctx context.Context, profile string) (*hcloud.ServerType, error) {
 	err := a.updateServerTypesUNLOCKED(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	var serverType *hcloud.ServerType
-	for _, t := range a.serverTypesCache {
-		if t.Name == profile {
-			serverType = t
-			break
+	serverType := a.findServerTypeUNLOCKED(profile)
+	if serverType == nil {
+		// The cache may predate a change to Hetzner's catalog, so try once more with fresh data.
+		slog.Debug("server type not in cache, refreshing", slog.String("profile", profile))
+		a.serverTypesCache = nil
+		err = a.updateServerTypesUNLOCKED(ctx)
+		if err != nil {
+			return nil, err
 		}
+		serverType = a.findServerTypeUNLOCKED(profile)
 	}
 
 	if serverType == nil {
-		return fmt.Errorf("hcloud: server type not found: %s", profile)
+		return nil, fmt.Errorf("hcloud: server type not found: %s", profile)
+	}
+	if current := a.server.ServerType; !a.opts.AllowArchitectureChange && serverType.Architecture != current.Architecture {
+		return nil, fmt.Errorf("hcloud: refusing to resize from %s (%s) to %s (%s), which has a different architecture", current.Name, current.Architecture, serverType.Name, serverType.Architecture)
+	}
+	return serverType, nil
+}
+
+func (a *HCloudAutoscaler) resizeServer(ctx context.Context, profile string, upgradeDisk bool) error {
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	serverType, err := a.resizeTargetUNLOCKED(ctx, profile)
+	if err != nil {
+		return err
 	}
 
-	err = a.resizeServerInner(ctx, serverType)
+	err = a.resizeServerInner(ctx, serverType, upgradeDisk)
 	if err != nil {
 		slog.Warn("hcloud: server resize failed, starting up manually", slog.String("err", err.Error()))
-		// Start it up again
-		_, _, err := a.api.Server.Poweron(ctx, a.server)
+		// Start it up again, even if the resize failed because ctx was cancelled.
+		_, _, err := a.api.Server.Poweron(context.WithoutCancel(ctx), a.server)
 		if err != nil {
 			return fmt.Errorf("hcloud: failed to power on server: %w", err)
 		}
+	} else {
+		// The server's type has changed, so the cached view of what's available is out of date.
+		a.serverTypesCache = nil
 	}
 	return err
 }
 
-func (a *HCloudAutoscaler) resizeServerInner(ctx context.Context, serverType *hcloud.ServerType) error {
-	action, _, err := a.api.Server.ChangeType(ctx, a.server, hcloud.ServerChangeTypeOpts{
-		ServerType:  serverType,
-		UpgradeDisk: false,
-	})
+func (a *HCloudAutoscaler) findServerTypeUNLOCKED(name string) *hcloud.ServerType {
+	for _, t := range a.serverTypesCache {
+		if t.Name == name {
+			return t
+		}
+	}
+	return nil
+}
+
+func (a *HCloudAutoscaler) resizeServerInner(ctx context.Context, serverType *hcloud.ServerType, upgradeDisk bool) error {
+	action, err := a.changeType(ctx, serverType, upgradeDisk)
+	if hcloud.IsError(err, hcloud.ErrorCodeConflict, hcloud.ErrorCodeLocked) {
+		// The server changed under us, e.g. it was restarted out of band, so try once more with its current state.
+		slog.Warn("hcloud: server changed during resize, retrying", slog.String("err", err.Error()))
+		select {
+		case <-time.After(5 * time.Second):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		action, err = a.changeType(ctx, serverType, upgradeDisk)
+	}
 	if err != nil {
 		return fmt.Errorf("hcloud: failed to resize server: %w", err)
 	}
@@ -202,6 +355,23 @@ func (a *HCloudAutoscaler) resizeServerInner(ctx context.Context, serverType *hc
 	return a.waitForAction(ctx, action)
 }
 
+// changeType re-fetches the server, so as not to act on a stale view of it, then changes its type.
+func (a *HCloudAutoscaler) changeType(ctx context.Context, serverType *hcloud.ServerType, upgradeDisk bool) (*hcloud.Action, error) {
+	server, _, err := a.api.Server.GetByID(ctx, a.server.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get server by ID: %w", err)
+	}
+	if server == nil {
+		return nil, fmt.Errorf("server not found")
+	}
+	a.server = server
+	action, _, err := a.api.Server.ChangeType(ctx, a.server, hcloud.ServerChangeTypeOpts{
+		ServerType:  serverType,
+		UpgradeDisk: upgradeDisk,
+	})
+	return action, err
+}
+
 func (a *HCloudAutoscaler) waitForAction(ctx context.Context, action *hcloud.Action) error {
 	attempt := 0
 	for {
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"text/tabwriter"
+	"time"
+
+	"github.com/markspolakovs/mcas/autoscaler"
+	"github.com/markspolakovs/mcas/metrics"
+)
+
+// simulatedProvider passes read-only calls through to the real provider, but
+// only pretends to stop and resize the server.
+type simulatedProvider struct {
+	autoscaler.Provider
+	size string
+}
+
+func (p *simulatedProvider) Refresh(ctx context.Context) error { return nil }
+
+func (p *simulatedProvider) GetCurrentSize(ctx context.Context) (string, error) {
+	return p.size, nil
+}
+
+func (p *simulatedProvider) StopServer(ctx context.Context) error { return nil }
+
+func (p *simulatedProvider) ResizeServer(ctx context.Context, size string) error {
+	p.size = size
+	return nil
+}
+
+// simulatedController stands in for the Minecraft server, which can't be asked
+// about the past. Player counts come from --metrics.player-count-query instead.
+type simulatedController struct{}
+
+func (simulatedController) Broadcast(ctx context.Context, message string) error { return nil }
+
+func (simulatedController) PlayerCount(ctx context.Context) (int, error) {
+	return 0, fmt.Errorf("can't get past player counts from the server")
+}
+
+func (simulatedController) Command(ctx context.Context, command string) (string, error) {
+	return "", fmt.Errorf("can't run commands in a simulation")
+}
+
+func (simulatedController) Stop(ctx context.Context) error { return nil }
+
+func (simulatedController) Close() error { return nil }
+
+// newSimulatedAutoscaler returns an autoscaler for replaying the rules against
+// Prometheus history, with room for the given number of events, and its stand-in
+// provider. If startSize is empty, it starts at the server's current size.
+func newSimulatedAutoscaler(ctx context.Context, args Options, logger *slog.Logger, startSize string, events int) (*autoscaler.Autoscaler, *simulatedProvider, error) {
+	if args.ScaleDownEmptyFor > 0 && args.Metrics.PlayerCountQuery == "" {
+		return nil, nil, fmt.Errorf("--scale-down-empty-for needs --metrics.player-count-query to get past player counts from Prometheus")
+	}
+	rulesFile, err := loadRules(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	mcMetrics, err := newMetrics(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	provider, serverName, err := newProvider(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if startSize == "" {
+		startSize, err = provider.GetCurrentSize(ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get current size: %w", err)
+		}
+	}
+	simulated := &simulatedProvider{Provider: provider, size: startSize}
+
+	cfg := autoscalerConfig(args, rulesFile)
+	cfg.Logger = logger
+	cfg.Metrics = mcMetrics
+	cfg.SelfMetrics = metrics.NewSelfMetrics()
+	cfg.Scaler = simulated
+	cfg.ServerName = serverName
+	cfg.Controller = simulatedController{}
+	// Nothing here can wait for the past, or reach the real server.
+	cfg.RecentEventsSize = events
+	cfg.EmptyCheck = autoscaler.EmptyCheckNone
+	cfg.PreShutdownDelay = 0
+	cfg.SkipStopCommand = true
+	cfg.ReadyTimeout = 0
+	cfg.DrainCommand = ""
+	cfg.PingAddress = ""
+	cfg.RconMetricCommand = ""
+	cfg.NotifyClampedEvery = 0
+	return autoscaler.NewAutoscaler(cfg), simulated, nil
+}
+
+// simulate replays the rules over a past period and prints what mcas would have done.
+func simulate(args Options, logger *slog.Logger) error {
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	from, to, step := args.Simulate.From, args.Simulate.To, args.Simulate.Step
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if step <= 0 {
+		step = args.Interval
+	}
+	if !from.Before(to) {
+		return fmt.Errorf("--from must be before --to")
+	}
+
+	a, _, err := newSimulatedAutoscaler(ctx, args, logger, args.Simulate.StartSize, int(to.Sub(from)/step)+1)
+	if err != nil {
+		return err
+	}
+
+	events := a.Simulate(ctx, from, to, step)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tFROM\tTO\tOUTCOME\tTRIGGER")
+	for _, e := range events {
+		outcome := e.Outcome
+		if e.Error != "" {
+			outcome += ": " + e.Error
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", e.Time.Format(time.RFC3339), e.From, e.To, outcome, e.Trigger)
+	}
+	fmt.Fprintf(w, "%d scaling actions between %s and %s\n", len(events), from.Format(time.RFC3339), to.Format(time.RFC3339))
+	return w.Flush()
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSimulateNeedsPastPlayerCountsForIdle(t *testing.T) {
+	var args Options
+	args.ScaleDownEmptyFor = 10 * time.Minute
+	_, _, err := newSimulatedAutoscaler(context.Background(), args, slog.New(slog.NewTextHandler(io.Discard, nil)), "", 1)
+	if err == nil || !strings.Contains(err.Error(), "--metrics.player-count-query") {
+		t.Errorf("newSimulatedAutoscaler() error = %v, want it to ask for --metrics.player-count-query", err)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// Set at build time with e.g. -ldflags "-X main.version=v1.2.3 -X main.commit=abc123 -X main.date=2025-01-01".
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+func buildVersion() (v, c, d string) {
+	v, c, d = version, commit, date
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return
+	}
+	if v == "" {
+		v = info.Main.Version
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if c == "" {
+				c = setting.Value
+			}
+		case "vcs.time":
+			if d == "" {
+				d = setting.Value
+			}
+		}
+	}
+	return
+}
+
+func printVersion() {
+	v, c, d := buildVersion()
+	fmt.Printf("mcas %s\ncommit: %s\nbuilt: %s\n", orUnknown(v), orUnknown(c), orUnknown(d))
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}